@@ -0,0 +1,175 @@
+// Package config loads the settings for the APIs in this repo, the
+// publications and reading list APIs and the todo APIs.  Settings are
+// layered, each layer overrides the one before it:
+//
+//  1. the defaults the API starts its config with
+//  2. a YAML or TOML file, picked with -config or the API's config
+//     environment variable
+//  3. environment variables, which is how containers are usually set up
+//  4. command line flags
+//
+// Each API has its own config struct that implements Config.  The parts
+// the APIs have in common, like the server, cache and CORS settings, are
+// in shared.go.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is implemented by the config struct of an API, it has to be a
+// pointer to a struct
+type Config interface {
+	//BindFlags adds a flag for every setting that writes into the config
+	BindFlags(fs *flag.FlagSet)
+	//Settings lists the environment variable for every flag
+	Settings() []Setting
+	//Validate checks every setting and reports all of the problems at once
+	Validate() error
+}
+
+// Setting ties a flag to the environment variable that can also set it
+type Setting struct {
+	Flag string
+	Env  string
+}
+
+// Load fills cfg from the layers described above, cfg has to hold the
+// defaults when it is passed in.  args are the command line arguments
+// without the program name and configEnv is the environment variable that
+// names the config file.  The config is validated before Load returns
+func Load(cfg Config, args []string, configEnv string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, not %T", cfg)
+	}
+	defaults := reflect.New(v.Elem().Type()).Elem()
+	defaults.Set(v.Elem())
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(configEnv), "YAML or TOML config file, or set "+configEnv)
+	cfg.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	//Parsing the flags wrote them into cfg, but the file and the
+	//environment have to be applied first.  So remember the flags that
+	//were given, put the defaults back, and apply them again at the end
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	v.Elem().Set(defaults)
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, cfg); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range cfg.Settings() {
+		if envVal, ok := os.LookupEnv(s.Env); ok && envVal != "" {
+			if err := fs.Set(s.Flag, envVal); err != nil {
+				return fmt.Errorf("%s: %w", s.Env, err)
+			}
+		}
+	}
+
+	for name, value := range given {
+		if name == "config" {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+
+	return cfg.Validate()
+}
+
+// loadFile reads a YAML (.yaml or .yml) or TOML (.toml) file over cfg.
+// Settings that are left out of the file keep their value, settings we
+// don't know about are an error so typos don't go unnoticed
+func loadFile(path string, cfg Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil //an empty file
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// String returns cfg as YAML, it is printed when an API starts so pass a
+// copy with the secrets replaced, see Redact
+func String(cfg interface{}) string {
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+// Redact returns stars for a secret that is set, so the config can be
+// printed without it
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "*****"
+}
+
+// Problems collects what is wrong with a config so Validate can report
+// all of it at once
+type Problems []error
+
+// Check adds a problem when ok is false
+func (p *Problems) Check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		*p = append(*p, fmt.Errorf(format, args...))
+	}
+}
+
+// Err joins the problems, it is nil when there are none
+func (p Problems) Err() error {
+	return errors.Join(p...)
+}
+
+// OneOf reports if s is one of the choices
+func OneOf(s string, choices ...string) bool {
+	for _, c := range choices {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Server is how an API listens and how long it waits on callers
+type Server struct {
+	Host          string   `yaml:"host" toml:"host"`
+	Port          uint     `yaml:"port" toml:"port"`
+	ReadTimeout   Duration `yaml:"readTimeout" toml:"readTimeout"`
+	WriteTimeout  Duration `yaml:"writeTimeout" toml:"writeTimeout"`
+	IdleTimeout   Duration `yaml:"idleTimeout" toml:"idleTimeout"`
+	ShutdownGrace Duration `yaml:"shutdownGrace" toml:"shutdownGrace"`
+}
+
+// DefaultServer listens on all interfaces on port.  docker compose waits
+// 10 seconds before it kills a container so the grace period is a bit
+// less than that
+func DefaultServer(port uint) Server {
+	return Server{
+		Host:          "0.0.0.0",
+		Port:          port,
+		ReadTimeout:   Duration(15 * time.Second),
+		WriteTimeout:  Duration(30 * time.Second),
+		IdleTimeout:   Duration(60 * time.Second),
+		ShutdownGrace: Duration(8 * time.Second),
+	}
+}
+
+// Addr is the address the API listens on
+func (s *Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+func (s *Server) BindFlags(fs *flag.FlagSet) {
+	//With TCP/IP the address 0.0.0.0 instructs the network stack to listen
+	//on all interfaces, localhost is only reachable from this machine
+	fs.StringVar(&s.Host, "h", s.Host, "Listen on all interfaces")
+	fs.UintVar(&s.Port, "p", s.Port, "Default Port")
+	fs.Var(&s.ReadTimeout, "read-timeout", "Longest time to read a request")
+	fs.Var(&s.WriteTimeout, "write-timeout", "Longest time to write a response")
+	fs.Var(&s.IdleTimeout, "idle-timeout", "How long to keep idle keep-alive connections")
+	fs.Var(&s.ShutdownGrace, "shutdown-grace", "How long to wait for requests to finish when stopping")
+}
+
+// ServerSettings are the environment variables for the server flags, each
+// starts with prefix, like PUBAPI_
+func ServerSettings(prefix string) []Setting {
+	return []Setting{
+		{"h", prefix + "HOST"},
+		{"p", prefix + "PORT"},
+		{"read-timeout", prefix + "READ_TIMEOUT"},
+		{"write-timeout", prefix + "WRITE_TIMEOUT"},
+		{"idle-timeout", prefix + "IDLE_TIMEOUT"},
+		{"shutdown-grace", prefix + "SHUTDOWN_GRACE"},
+	}
+}
+
+func (s *Server) Check(p *Problems) {
+	p.Check(s.Port > 0 && s.Port <= 65535, "server port %d must be between 1 and 65535", s.Port)
+	p.Check(s.ReadTimeout > 0, "server read timeout must be more than 0")
+	p.Check(s.WriteTimeout > 0, "server write timeout must be more than 0")
+	p.Check(s.IdleTimeout > 0, "server idle timeout must be more than 0")
+	p.Check(s.ShutdownGrace >= 0, "server shutdown grace can't be negative")
+}
+
+// Cache is the redis an API keeps its data in
+type Cache struct {
+	Addr      string `yaml:"addr" toml:"addr"`
+	Username  string `yaml:"username" toml:"username"`
+	Password  string `yaml:"password" toml:"password"`
+	DB        int    `yaml:"db" toml:"db"`
+	TLS       bool   `yaml:"tls" toml:"tls"`
+	TLSCAFile string `yaml:"tlsCAFile" toml:"tlsCAFile"` //trust this CA as well as the system ones
+}
+
+// BindFlags adds the cache flags.  The APIs named them before they shared
+// this package, so addrFlag is the flag for the address and name starts
+// the others.  The publications API has -c and -cache-password, the todo
+// APIs have -redis and -redis-password
+func (c *Cache) BindFlags(fs *flag.FlagSet, addrFlag, name string) {
+	fs.StringVar(&c.Addr, addrFlag, c.Addr, "Redis host:port")
+	fs.StringVar(&c.Username, name+"-username", c.Username, "Redis ACL user name")
+	fs.StringVar(&c.Password, name+"-password", c.Password, "Redis password, prefer the environment variable so it is not in the process list")
+	fs.IntVar(&c.DB, name+"-db", c.DB, "Redis database number")
+	fs.BoolVar(&c.TLS, name+"-tls", c.TLS, "Connect to redis with TLS")
+	fs.StringVar(&c.TLSCAFile, name+"-tls-ca", c.TLSCAFile, "PEM file with a CA to trust for redis TLS")
+}
+
+// CacheSettings are the environment variables for the cache flags, each
+// starts with envPrefix, like PUBAPI_CACHE_ for PUBAPI_CACHE_URL
+func CacheSettings(addrFlag, name, envPrefix string) []Setting {
+	return []Setting{
+		{addrFlag, envPrefix + "URL"},
+		{name + "-username", envPrefix + "USERNAME"},
+		{name + "-password", envPrefix + "PASSWORD"},
+		{name + "-db", envPrefix + "DB"},
+		{name + "-tls", envPrefix + "TLS"},
+		{name + "-tls-ca", envPrefix + "TLS_CA_FILE"},
+	}
+}
+
+func (c *Cache) Check(p *Problems) {
+	p.Check(c.Addr != "", "cache address is required")
+	p.Check(c.DB >= 0, "cache db %d can't be negative", c.DB)
+	if c.TLSCAFile != "" {
+		p.Check(c.TLS, "cache tlsCAFile is set but tls is off")
+		_, err := os.Stat(c.TLSCAFile)
+		p.Check(err == nil, "cache tlsCAFile: %v", err)
+	}
+}
+
+// Redacted is a copy of the settings with the password starred out
+func (c Cache) Redacted() Cache {
+	c.Password = Redact(c.Password)
+	return c
+}
+
+// RedisOptions turns the settings into options for a redis client, every
+// client of the cache should be made from them
+func (c *Cache) RedisOptions() (*redis.Options, error) {
+	opts := &redis.Options{
+		Addr:     c.Addr,
+		Username: c.Username,
+		Password: c.Password,
+		DB:       c.DB,
+	}
+	if !c.TLS {
+		return opts, nil
+	}
+
+	opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.TLSCAFile)
+		}
+		opts.TLSConfig.RootCAs = pool
+	}
+	return opts, nil
+}
+
+// CORS is the origins that browsers can call an API from, a * allows any
+// origin.  See httpkit.CORSConfig
+type CORS struct {
+	Origins StringList `yaml:"origins" toml:"origins"`
+}
+
+func DefaultCORS() CORS {
+	return CORS{Origins: StringList{"*"}}
+}
+
+func (c *CORS) BindFlags(fs *flag.FlagSet) {
+	fs.Var(&c.Origins, "cors-origins", "Comma separated origins browsers can call the API from, * for any")
+}
+
+// CORSSettings are the environment variables for the CORS flags
+func CORSSettings(prefix string) []Setting {
+	return []Setting{
+		{"cors-origins", prefix + "CORS_ORIGINS"},
+	}
+}
+
+func (c *CORS) Check(p *Problems) {
+	//cors panics on an empty list, no origins at all is never what was
+	//meant
+	p.Check(len(c.Origins) > 0, "cors origins can't be empty, use * to allow any origin")
+	for _, origin := range c.Origins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		p.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "",
+			"cors origin %q must be * or look like https://example.com", origin)
+	}
+}
+
+// Log picks the format and level of the app log and of the access log
+type Log struct {
+	Format       string `yaml:"format" toml:"format"`             //json or text
+	Level        string `yaml:"level" toml:"level"`               //lowest level logged
+	AccessFormat string `yaml:"accessFormat" toml:"accessFormat"` //json, text or off
+	AccessLevel  string `yaml:"accessLevel" toml:"accessLevel"`   //level of the lines for requests that worked
+}
+
+func DefaultLog() Log {
+	return Log{Format: "json", Level: "info", AccessFormat: "json", AccessLevel: "info"}
+}
+
+func (l *Log) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&l.Format, "log-format", l.Format, "Log format: json or text")
+	fs.StringVar(&l.Level, "log-level", l.Level, "Lowest level logged: debug, info, warn or error")
+	fs.StringVar(&l.AccessFormat, "access-log-format", l.AccessFormat, "Access log format: json, text or off")
+	fs.StringVar(&l.AccessLevel, "access-log-level", l.AccessLevel, "Level of access log lines for requests that worked")
+}
+
+// LogSettings are the environment variables for the log flags
+func LogSettings(prefix string) []Setting {
+	return []Setting{
+		{"log-format", prefix + "LOG_FORMAT"},
+		{"log-level", prefix + "LOG_LEVEL"},
+		{"access-log-format", prefix + "ACCESS_LOG_FORMAT"},
+		{"access-log-level", prefix + "ACCESS_LOG_LEVEL"},
+	}
+}
+
+func (l *Log) Check(p *Problems) {
+	levels := []string{"debug", "info", "warn", "error"}
+	p.Check(OneOf(l.Format, "json", "text"), "log format %q must be json or text", l.Format)
+	p.Check(OneOf(strings.ToLower(l.Level), levels...), "log level %q must be debug, info, warn or error", l.Level)
+	p.Check(OneOf(l.AccessFormat, "json", "text", "off"), "access log format %q must be json, text or off", l.AccessFormat)
+	p.Check(OneOf(strings.ToLower(l.AccessLevel), levels...), "access log level %q must be debug, info, warn or error", l.AccessLevel)
+}
+
+// RateLimit says where the rate limit buckets are kept and how big they
+// are, see httpkit.RateLimiter
+type RateLimit struct {
+	Store   string `yaml:"store" toml:"store"`     //memory, redis or off
+	Default int    `yaml:"default" toml:"default"` //requests a minute
+}
+
+func DefaultRateLimit() RateLimit {
+	return RateLimit{Store: "memory", Default: 120}
+}
+
+func (r *RateLimit) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&r.Store, "rate-limit", r.Store, "Where to keep rate limits: memory, redis or off")
+	fs.IntVar(&r.Default, "rate-limit-default", r.Default, "Requests a minute from one caller to each route")
+}
+
+// RateLimitSettings are the environment variables for the rate limit flags
+func RateLimitSettings(prefix string) []Setting {
+	return []Setting{
+		{"rate-limit", prefix + "RATE_LIMIT"},
+		{"rate-limit-default", prefix + "RATE_LIMIT_DEFAULT"},
+	}
+}
+
+func (r *RateLimit) Check(p *Problems) {
+	p.Check(OneOf(r.Store, "memory", "redis", "off"), "rate limit store %q must be memory, redis or off", r.Store)
+	p.Check(r.Default > 0, "rate limit default must be more than 0")
+}
+
+// CheckURL adds a problem when s is not an absolute http or https URL,
+// name says which setting it is
+func CheckURL(p *Problems, name, s string) {
+	u, err := url.Parse(s)
+	p.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"%s %q must look like http://host:port", name, s)
+}
+
+// Duration is a time.Duration that is written as "15s" in flags, files
+// and environment variables
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	return d.Set(string(b))
+}
+
+// Std returns the value as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// StringList is a list that is written comma separated in flags and
+// environment variables, and as a list in files
+type StringList []string
+
+func (l StringList) String() string {
+	return strings.Join(l, ",")
+}
+
+func (l *StringList) Set(s string) error {
+	*l = StringList{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
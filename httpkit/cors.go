@@ -0,0 +1,22 @@
+package httpkit
+
+import (
+	"github.com/gin-contrib/cors"
+)
+
+// CORSConfig lets browsers call an API from origins, a * in the list
+// allows any origin.  The request id header can be sent and read, an API
+// adds the other headers it uses to the config
+func CORSConfig(origins []string) cors.Config {
+	c := cors.DefaultConfig()
+	c.AllowHeaders = append(c.AllowHeaders, RequestIDHeader)
+	c.ExposeHeaders = append(c.ExposeHeaders, RequestIDHeader)
+	for _, origin := range origins {
+		if origin == "*" {
+			c.AllowAllOrigins = true
+			return c
+		}
+	}
+	c.AllowOrigins = origins
+	return c
+}
@@ -0,0 +1,8 @@
+// Package httpkit is the gin middleware that the publications API and the
+// reading list API share, so the two services tag requests, log, answer
+// errors and throttle callers the same way.  The todo APIs use its rate
+// limiter too.  Errors are sent as a Problem, which is here and not in
+// pubschema so the schema doesn't need to know about HTTP.  The config sub
+// package loads the settings of every API in the repo, each one keeps only
+// its own config struct.
+package httpkit
@@ -0,0 +1,25 @@
+package httpkit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeDownload sends what write writes as a file to download, like a
+// bibliography.  It is written to a buffer first so that a failure can
+// still be sent as a 500 problem
+func ServeDownload(c *gin.Context, contentType, filename string, write func(w io.Writer) error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		LogError(c, err)
+		AbortWithStatus(c, http.StatusInternalServerError, InternalErrorDetail)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,154 @@
+package httpkit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the id of a request between the APIs.  The
+// reading list API sends it on its calls to the publications API so the
+// log lines for one request can be found in both services
+const RequestIDHeader = "X-Request-ID"
+
+// MaxRequestIDLength is the longest request id that is taken from a
+// caller, longer ones are replaced with a new id
+const MaxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random 16 byte id as hex
+func NewRequestID() string {
+	b := make([]byte, 16)
+	//crypto/rand does not fail on the platforms we run on
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID reports if an id from a caller can be used as is.  Ids
+// end up in logs and headers so only short ones made of letters, digits
+// and - _ . : are taken
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_.:", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id, or "" if ctx does not have
+// one
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID gives every request an id.  The id from the X-Request-ID
+// header is used if the caller sent a good one, otherwise a new one is
+// made.  It is put on the request context, so log lines written with
+// slog.InfoContext and friends have it, and sent back on the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !ValidRequestID(id) {
+			id = NewRequestID()
+		}
+		c.Request = c.Request.WithContext(ContextWithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLog writes a line to logger for every request.  Requests that
+// worked are logged at level, 4xx responses at least at warn and 5xx
+// responses at error, so the requests that failed stand out.  logger must
+// write lines at level, or they are dropped.  It must run after RequestID
+func AccessLog(logger *slog.Logger, level slog.Level) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		lineLevel := level
+		switch {
+		case status >= 500:
+			lineLevel = slog.LevelError
+		case status >= 400:
+			lineLevel = max(level, slog.LevelWarn)
+		}
+		logger.LogAttrs(c.Request.Context(), lineLevel, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// ParseLogLevel turns debug, info, warn or error into a slog level
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("log level %q must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// NewLogger returns a logger that writes json or text lines at level and
+// above.  Lines logged with a context that has a request id get a
+// request_id attribute, so use the Context versions like slog.InfoContext
+// while handling a request
+func NewLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch format {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("log format %q must be json or text", format)
+	}
+	return slog.New(requestIDHandler{h}), nil
+}
+
+// requestIDHandler adds the request id from the context to every record
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
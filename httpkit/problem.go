@@ -0,0 +1,161 @@
+package httpkit
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of error responses, see RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// The APIs wrap these errors when what a request is about is missing,
+// already exists, or is not valid.  StatusForError is the one place that
+// turns them into a status
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+	ErrInvalid  = errors.New("not valid")
+)
+
+// Problem is the body of every error response.  There are no pages
+// documenting each kind of problem so Type is about:blank, which means the
+// status says everything and Title is its usual text.  Extensions are
+// extra members added next to the standard ones, like the import report
+// when an import fails part of the way through
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// StatusForError picks the HTTP status for ErrNotFound, ErrConflict and
+// ErrInvalid, anything else is a 500
+func StatusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// With adds an extension member to the problem
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// MarshalJSON puts the extensions at the top level of the object, the
+// standard members win if an extension uses the same name
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem //no MarshalJSON, so no recursion
+	std, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return std, err
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	var stdMembers map[string]interface{}
+	if err := json.Unmarshal(std, &stdMembers); err != nil {
+		return nil, err
+	}
+	for k, v := range stdMembers {
+		members[k] = v
+	}
+	return json.Marshal(members)
+}
+
+// WriteProblem sends a problem as the HTTP response
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		//an extension that can't be encoded, send the problem without them
+		plain := *p
+		plain.Extensions = nil
+		body, _ = json.Marshal(plain)
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// AbortWithProblem sends an RFC 7807 problem and stops the request, every
+// error from the APIs goes through here so they all look the same
+func AbortWithProblem(c *gin.Context, p *Problem) {
+	p.Instance = c.Request.URL.Path
+	c.Abort()
+	WriteProblem(c.Writer, p)
+}
+
+// AbortWithStatus sends a problem with the status and a message for the
+// caller
+func AbortWithStatus(c *gin.Context, status int, detail string) {
+	AbortWithProblem(c, NewProblem(status, detail))
+}
+
+// InternalErrorDetail is the detail of every 5xx, what went wrong is about
+// the server and not the request, so it is only logged
+const InternalErrorDetail = "The request could not be completed, try again later"
+
+// AbortWithError sends the problem for an error, StatusForError picks the
+// status
+func AbortWithError(c *gin.Context, err error) {
+	AbortWithErrorStatus(c, StatusForError(err), err)
+}
+
+// AbortWithErrorStatus sends the problem for an error with a status the
+// caller picked.  A 5xx is logged and the caller gets InternalErrorDetail,
+// the error can name hosts, keys or queries that the caller should not see
+func AbortWithErrorStatus(c *gin.Context, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		LogError(c, err)
+		AbortWithStatus(c, status, InternalErrorDetail)
+		return
+	}
+	AbortWithStatus(c, status, err.Error())
+}
+
+// LogError logs an error that the caller is not told about
+func LogError(c *gin.Context, err error) {
+	slog.ErrorContext(c.Request.Context(), "request failed",
+		"method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+}
+
+// NoRoute sends a 404 problem for paths the API does not have
+func NoRoute(c *gin.Context) {
+	AbortWithStatus(c, http.StatusNotFound, "There is nothing at "+c.Request.URL.Path)
+}
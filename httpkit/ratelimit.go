@@ -0,0 +1,152 @@
+package httpkit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// Limit is the size of a token bucket and how fast it refills.  Each
+// caller gets a bucket per route that holds up to Burst tokens and refills
+// at Rate tokens a second, every request takes a token
+type Limit struct {
+	Rate  float64 //tokens per second
+	Burst int
+}
+
+// PerMinute allows n requests a minute, all of which can be used at once
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+func (l Limit) IsZero() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// RateResult is what happened when a token was taken from a bucket
+type RateResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration //when the next token is available, if not allowed
+	Reset      time.Duration //when the bucket is full again
+}
+
+// RateStore holds the buckets, in memory for a single instance or in
+// redis so that replicas share them
+type RateStore interface {
+	Take(ctx context.Context, key string, l Limit) (RateResult, error)
+	Close() error
+}
+
+// rateResult works out the RateResult from the tokens left after a request
+func rateResult(allowed bool, tokens float64, l Limit) RateResult {
+	res := RateResult{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(l.Burst) - tokens) / l.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = secondsToDuration((1 - tokens) / l.Rate)
+	}
+	return res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimiter builds the rate limit middleware for each route
+type RateLimiter struct {
+	store   RateStore
+	Default Limit //used by routes that don't have their own limit
+	PerIP   Limit //for each IP address before the caller is known, see ByIP
+
+	//Key says who the caller is, like their user name.  It is their IP
+	//address unless the API sets it, the publications and reading list
+	//APIs don't have their own login
+	Key func(c *gin.Context) string
+}
+
+func NewRateLimiter(store RateStore, def Limit) *RateLimiter {
+	return &RateLimiter{store: store, Default: def, PerIP: def, Key: ByIPKey}
+}
+
+// ByIPKey is the caller's IP address, it is the default RateLimiter.Key
+func ByIPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// NewRateStore returns the store for a -rate-limit setting, memory, redis
+// or off.  It is nil for off.  redisOpts is only used by the redis store
+func NewRateStore(kind string, redisOpts *redis.Options) (RateStore, error) {
+	switch kind {
+	case "off":
+		return nil, nil
+	case "memory":
+		return NewMemoryRateStore(), nil
+	case "redis":
+		return NewRedisRateStore(redisOpts), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q, use memory, redis or off", kind)
+	}
+}
+
+// Close releases the store, it is called when the API shuts down
+func (lim *RateLimiter) Close() error {
+	return lim.store.Close()
+}
+
+// Handler returns the middleware for one route, a zero Limit uses the
+// default.  Each caller has their own bucket for every route, so a burst
+// of reads does not use up the writes.  If the store can't be reached the
+// request is let through, an outage of the cache should not take the API
+// down with it
+func (lim *RateLimiter) Handler(l Limit) gin.HandlerFunc {
+	if l.IsZero() {
+		l = lim.Default
+	}
+	return lim.handler(l, func(c *gin.Context) string {
+		return fmt.Sprintf("%s %s:%s", c.Request.Method, c.FullPath(), lim.Key(c))
+	})
+}
+
+// ByIP returns middleware that gives each IP address one bucket of PerIP
+// for all of the routes that use it.  An API that knows the caller from a
+// token runs it before the token is checked, the per route limits count
+// by user so they can't see callers that send a flood of bad tokens
+func (lim *RateLimiter) ByIP() gin.HandlerFunc {
+	return lim.handler(lim.PerIP, ByIPKey)
+}
+
+func (lim *RateLimiter) handler(l Limit, key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := lim.store.Take(c.Request.Context(), key(c), l)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Error checking rate limit, allowing request", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(l.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			retry := strconv.Itoa(ceilSeconds(res.RetryAfter))
+			c.Header("Retry-After", retry)
+			AbortWithStatus(c, http.StatusTooManyRequests, "Too many requests, try again in "+retry+" seconds")
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
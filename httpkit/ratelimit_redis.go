@@ -0,0 +1,75 @@
+package httpkit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const RateKeyPrefix = "ratelimit:"
+
+// takeScript does the whole token bucket update in redis so that replicas
+// can't race each other.  The bucket is a hash with the tokens left and
+// when it was last used, in milliseconds from the redis clock so the
+// clocks of the replicas don't matter.  The key expires once the bucket
+// would be full again.  The tokens are returned as a string since redis
+// turns lua numbers into integers
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) * 1000 / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateStore keeps the buckets in redis, use it when there is more
+// than one instance of the API
+type RedisRateStore struct {
+	client *redis.Client
+}
+
+// NewRedisRateStore connects to redis with its own client, so it can be
+// closed on its own
+func NewRedisRateStore(opts *redis.Options) *RedisRateStore {
+	return &RedisRateStore{client: redis.NewClient(opts)}
+}
+
+func (r *RedisRateStore) Close() error {
+	return r.client.Close()
+}
+
+func (r *RedisRateStore) Take(ctx context.Context, key string, l Limit) (RateResult, error) {
+	val, err := takeScript.Run(ctx, r.client, []string{RateKeyPrefix + key}, l.Rate, l.Burst).Result()
+	if err != nil {
+		return RateResult{}, err
+	}
+	reply, ok := val.([]interface{})
+	if !ok || len(reply) != 2 {
+		return RateResult{}, fmt.Errorf("unexpected reply from the rate limit script: %v", val)
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokensS, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(tokensS, 64)
+	if err != nil {
+		return RateResult{}, err
+	}
+	return rateResult(allowed == 1, math.Max(0, tokens), l), nil
+}
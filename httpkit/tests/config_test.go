@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"architectingsoftware.com/httpkit/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConfig is a small config like the ones the APIs have
+type testConfig struct {
+	Server config.Server `yaml:"server" toml:"server"`
+	Log    config.Log    `yaml:"log" toml:"log"`
+}
+
+func newTestConfig() *testConfig {
+	return &testConfig{Server: config.DefaultServer(2080), Log: config.DefaultLog()}
+}
+
+func (c *testConfig) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+	c.Log.BindFlags(fs)
+}
+
+func (c *testConfig) Settings() []config.Setting {
+	return append(config.ServerSettings("TEST_"), config.LogSettings("TEST_")...)
+}
+
+func (c *testConfig) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	c.Log.Check(&p)
+	return p.Err()
+}
+
+func writeFile(t *testing.T, name, body string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(body), 0600))
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := newTestConfig()
+	require.NoError(t, config.Load(cfg, nil, "TEST_CONFIG"))
+	assert.Equal(t, "0.0.0.0:2080", cfg.Server.Addr())
+	assert.Equal(t, "info", cfg.Log.Level)
+}
+
+func TestConfigLayers(t *testing.T) {
+	file := writeFile(t, "api.yaml", "server:\n  port: 3000\n  host: 127.0.0.1\nlog:\n  level: debug\n  format: text\n")
+	t.Setenv("TEST_CONFIG", file)
+	t.Setenv("TEST_PORT", "4000")
+	t.Setenv("TEST_LOG_LEVEL", "warn")
+
+	cfg := newTestConfig()
+	require.NoError(t, config.Load(cfg, []string{"-log-level", "error"}, "TEST_CONFIG"))
+
+	//the file beats the defaults, the environment beats the file and the
+	//flags beat everything
+	assert.Equal(t, "127.0.0.1", cfg.Server.Host)
+	assert.Equal(t, uint(4000), cfg.Server.Port)
+	assert.Equal(t, "text", cfg.Log.Format)
+	assert.Equal(t, "error", cfg.Log.Level)
+}
+
+func TestConfigTOML(t *testing.T) {
+	file := writeFile(t, "api.toml", "[server]\nshutdownGrace = \"2s\"\n")
+	cfg := newTestConfig()
+	require.NoError(t, config.Load(cfg, []string{"-config", file}, "TEST_CONFIG"))
+	assert.Equal(t, "2s", cfg.Server.ShutdownGrace.String())
+}
+
+// demoConfig adds a setting to the shared server settings the way the todo
+// APIs do, the file keeps them all under server
+type demoConfig struct {
+	Server struct {
+		config.Server `yaml:",inline"`
+		DemoRoutes    bool `yaml:"demoRoutes" toml:"demoRoutes"`
+	} `yaml:"server" toml:"server"`
+}
+
+func (c *demoConfig) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+	fs.BoolVar(&c.Server.DemoRoutes, "enable-demo-routes", c.Server.DemoRoutes, "demo routes")
+}
+
+func (c *demoConfig) Settings() []config.Setting {
+	return config.ServerSettings("TEST_")
+}
+
+func (c *demoConfig) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	return p.Err()
+}
+
+func TestConfigEmbedded(t *testing.T) {
+	files := map[string]string{
+		"api.yaml": "server:\n  port: 3000\n  demoRoutes: true\n",
+		"api.toml": "[server]\nport = 3000\ndemoRoutes = true\n",
+	}
+	for name, body := range files {
+		cfg := &demoConfig{}
+		cfg.Server.Server = config.DefaultServer(2080)
+		require.NoError(t, config.Load(cfg, []string{"-config", writeFile(t, name, body)}, "TEST_CONFIG"), name)
+		assert.Equal(t, uint(3000), cfg.Server.Port, name)
+		assert.True(t, cfg.Server.DemoRoutes, name)
+		assert.Equal(t, "15s", cfg.Server.ReadTimeout.String(), name)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		file string
+		args []string
+		env  map[string]string
+	}{
+		"unknown file setting": {file: "server:\n  prot: 3000\n"},
+		"bad env value":        {env: map[string]string{"TEST_READ_TIMEOUT": "soon"}},
+		"bad flag":             {args: []string{"-p", "port"}},
+		"invalid setting":      {args: []string{"-access-log-format", "xml"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			args := tc.args
+			if tc.file != "" {
+				args = append([]string{"-config", writeFile(t, "api.yaml", tc.file)}, args...)
+			}
+			assert.Error(t, config.Load(newTestConfig(), args, "TEST_CONFIG"))
+		})
+	}
+}
+
+// cacheConfig has the cache and CORS settings, with the names the
+// publications API uses
+type cacheConfig struct {
+	Cache config.Cache `yaml:"cache" toml:"cache"`
+	CORS  config.CORS  `yaml:"cors" toml:"cors"`
+}
+
+func (c *cacheConfig) BindFlags(fs *flag.FlagSet) {
+	c.Cache.BindFlags(fs, "c", "cache")
+	c.CORS.BindFlags(fs)
+}
+
+func (c *cacheConfig) Settings() []config.Setting {
+	return append(config.CacheSettings("c", "cache", "TEST_CACHE_"), config.CORSSettings("TEST_")...)
+}
+
+func (c *cacheConfig) Validate() error {
+	var p config.Problems
+	c.Cache.Check(&p)
+	c.CORS.Check(&p)
+	return p.Err()
+}
+
+func TestCacheSettings(t *testing.T) {
+	t.Setenv("TEST_CACHE_URL", "redis.internal:6380")
+	t.Setenv("TEST_CACHE_PASSWORD", "s3cret")
+	t.Setenv("TEST_CORS_ORIGINS", "https://a.example.com, https://b.example.com")
+
+	cfg := &cacheConfig{Cache: config.Cache{Addr: "0.0.0.0:6379"}, CORS: config.DefaultCORS()}
+	require.NoError(t, config.Load(cfg, []string{"-cache-db", "2", "-cache-username", "api"}, "TEST_CONFIG"))
+
+	opts, err := cfg.Cache.RedisOptions()
+	require.NoError(t, err)
+	assert.Equal(t, "redis.internal:6380", opts.Addr)
+	assert.Equal(t, "api", opts.Username)
+	assert.Equal(t, "s3cret", opts.Password)
+	assert.Equal(t, 2, opts.DB)
+	assert.Nil(t, opts.TLSConfig)
+	assert.Equal(t, config.StringList{"https://a.example.com", "https://b.example.com"}, cfg.CORS.Origins)
+
+	//the password is never printed
+	assert.NotContains(t, config.String(cfg.Cache.Redacted()), "s3cret")
+	assert.Equal(t, "s3cret", cfg.Cache.Password)
+}
+
+func TestCacheTLS(t *testing.T) {
+	cfg := &cacheConfig{Cache: config.Cache{Addr: "0.0.0.0:6379"}, CORS: config.DefaultCORS()}
+	require.NoError(t, config.Load(cfg, []string{"-cache-tls"}, "TEST_CONFIG"))
+
+	opts, err := cfg.Cache.RedisOptions()
+	require.NoError(t, err)
+	require.NotNil(t, opts.TLSConfig)
+	assert.Nil(t, opts.TLSConfig.RootCAs)
+}
+
+func TestCacheAndCORSErrors(t *testing.T) {
+	tests := map[string][]string{
+		"no address":            {"-c", ""},
+		"negative db":           {"-cache-db", "-1"},
+		"ca without tls":        {"-cache-tls-ca", "ca.pem"},
+		"missing ca":            {"-cache-tls", "-cache-tls-ca", "/no/such/ca.pem"},
+		"no origins":            {"-cors-origins", ""},
+		"origin with a path":    {"-cors-origins", "https://example.com/app"},
+		"origin without scheme": {"-cors-origins", "example.com"},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &cacheConfig{Cache: config.Cache{Addr: "0.0.0.0:6379"}, CORS: config.DefaultCORS()}
+			assert.Error(t, config.Load(cfg, args, "TEST_CONFIG"))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, httpkit.ValidRequestID(httpkit.NewRequestID()))
+	assert.True(t, httpkit.ValidRequestID("gw-1:abc_DEF.2"))
+
+	assert.False(t, httpkit.ValidRequestID(""))
+	assert.False(t, httpkit.ValidRequestID("has space"))
+	assert.False(t, httpkit.ValidRequestID("line\nbreak"))
+	assert.False(t, httpkit.ValidRequestID(strings.Repeat("a", httpkit.MaxRequestIDLength+1)))
+}
+
+func TestNewRequestIDIsUnique(t *testing.T) {
+	assert.NotEqual(t, httpkit.NewRequestID(), httpkit.NewRequestID())
+}
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := httpkit.NewLogger(&buf, "json", slog.LevelInfo)
+	require.NoError(t, err)
+
+	ctx := httpkit.ContextWithRequestID(context.Background(), "abc123")
+	logger.With("service", "test").InfoContext(ctx, "hello", "n", 1)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "abc123", line["request_id"])
+	assert.Equal(t, "test", line["service"])
+}
+
+func TestLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := httpkit.NewLogger(&buf, "text", slog.LevelWarn)
+	require.NoError(t, err)
+
+	logger.Info("quiet")
+	assert.Empty(t, buf.String())
+	logger.Warn("loud")
+	assert.Contains(t, buf.String(), "msg=loud")
+}
+
+func TestLoggerSettings(t *testing.T) {
+	_, err := httpkit.NewLogger(&bytes.Buffer{}, "xml", slog.LevelInfo)
+	assert.Error(t, err)
+
+	level, err := httpkit.ParseLogLevel("debug")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+	_, err = httpkit.ParseLogLevel("loud")
+	assert.Error(t, err)
+}
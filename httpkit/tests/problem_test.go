@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusForError(t *testing.T) {
+	tests := map[error]int{
+		fmt.Errorf("pubs:10: %w", httpkit.ErrNotFound): http.StatusNotFound,
+		fmt.Errorf("pubs:10: %w", httpkit.ErrConflict): http.StatusConflict,
+		fmt.Errorf("title: %w", httpkit.ErrInvalid):    http.StatusBadRequest,
+		errors.New("redis: connection refused"):        http.StatusInternalServerError,
+	}
+	for err, status := range tests {
+		assert.Equal(t, status, httpkit.StatusForError(err), err.Error())
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	p := httpkit.NewProblem(http.StatusNotFound, "pubs:10: not found")
+	p.Instance = "/pubs/10"
+
+	w := httptest.NewRecorder()
+	httpkit.WriteProblem(w, p)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "pubs:10: not found",
+		"instance": "/pubs/10"
+	}`, w.Body.String())
+}
+
+func TestProblemExtensions(t *testing.T) {
+	p := httpkit.NewProblem(http.StatusInternalServerError, "Could not save publication 20").
+		With("report", map[string]int{"created": 1}).
+		With("status", "ignored")
+
+	b, err := json.Marshal(p)
+	assert.NoError(t, err)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(b, &body))
+	assert.Equal(t, map[string]interface{}{"created": float64(1)}, body["report"])
+	//extensions can't replace the standard members
+	assert.Equal(t, float64(http.StatusInternalServerError), body["status"])
+	assert.Equal(t, "Internal Server Error", body["title"])
+}
+
+func abortWithError(err error) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/pubs/:id", func(c *gin.Context) {
+		httpkit.AbortWithError(c, err)
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pubs/10", nil))
+	return w
+}
+
+func TestAbortWithError(t *testing.T) {
+	w := abortWithError(fmt.Errorf("publication pubs:10: %w", httpkit.ErrNotFound))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "publication pubs:10: not found",
+		"instance": "/pubs/10"
+	}`, w.Body.String())
+}
+
+func TestAbortWithErrorHidesServerErrors(t *testing.T) {
+	w := abortWithError(errors.New("dial tcp 10.0.0.7:6379: connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.7")
+	var p httpkit.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, httpkit.InternalErrorDetail, p.Detail)
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rateLimitedRouter(def httpkit.Limit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	lim := httpkit.NewRateLimiter(httpkit.NewMemoryRateStore(), def)
+	r := gin.New()
+	r.Use(lim.Handler(httpkit.Limit{}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/a", ok)
+	r.GET("/b", ok)
+	return r
+}
+
+func get(r http.Handler, path, ip string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = ip + ":1234"
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitTooManyRequests(t *testing.T) {
+	r := rateLimitedRouter(httpkit.PerMinute(2))
+
+	w := get(r, "/a", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, get(r, "/a", "10.0.0.1").Code)
+
+	w = get(r, "/a", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"))
+
+	var p httpkit.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, http.StatusTooManyRequests, p.Status)
+	assert.Equal(t, "/a", p.Instance)
+}
+
+func TestRateLimitBucketPerRouteAndIP(t *testing.T) {
+	r := rateLimitedRouter(httpkit.PerMinute(1))
+
+	assert.Equal(t, http.StatusOK, get(r, "/a", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(r, "/a", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, get(r, "/b", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, get(r, "/a", "10.0.0.2").Code)
+}
+
+func TestNewRateStore(t *testing.T) {
+	store, err := httpkit.NewRateStore("off", nil)
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	store, err = httpkit.NewRateStore("memory", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &httpkit.MemoryRateStore{}, store)
+
+	_, err = httpkit.NewRateStore("disk", nil)
+	assert.Error(t, err)
+}
+
+func TestRateLimitKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lim := httpkit.NewRateLimiter(httpkit.NewMemoryRateStore(), httpkit.PerMinute(1))
+	lim.Key = func(c *gin.Context) string { return "user:" + c.GetHeader("X-User") }
+	r := gin.New()
+	r.GET("/a", lim.Handler(httpkit.Limit{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(user, ip string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		req.Header.Set("X-User", user)
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, send("alice", "10.0.0.1"))
+	//the bucket follows the user, not the address
+	assert.Equal(t, http.StatusTooManyRequests, send("alice", "10.0.0.2"))
+	assert.Equal(t, http.StatusOK, send("bob", "10.0.0.1"))
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lim := httpkit.NewRateLimiter(httpkit.NewMemoryRateStore(), httpkit.PerMinute(10))
+	lim.PerIP = httpkit.PerMinute(1)
+	r := gin.New()
+	r.Use(lim.ByIP())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/a", ok)
+	r.GET("/b", ok)
+
+	//one bucket for every route
+	assert.Equal(t, http.StatusOK, get(r, "/a", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, get(r, "/b", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, get(r, "/b", "10.0.0.2").Code)
+}
@@ -1,2 +1,5 @@
 #!/bin/bash
-kubectl create -k ./
\ No newline at end of file
+kubectl create -k ./
+#the reading list API does not start without a gateway token, nothing in
+#the cluster sends it so every X-User header from outside is turned away
+kubectl create secret generic publist-gateway -n cnse --from-literal=token=$(openssl rand -hex 32)
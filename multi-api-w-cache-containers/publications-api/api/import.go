@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/pubschema"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxImportBytes is the biggest import file that is read, the whole file
+// is held in memory while it is parsed
+const MaxImportBytes = 10 << 20
+
+// importFormats maps the Content-Type of an import to its format, the
+// format can also be given with ?format=
+var importFormats = map[string]string{
+	"application/x-bibtex": "bibtex",
+	"text/x-bibtex":        "bibtex",
+	"text/csv":             "csv",
+	"application/json":     "json",
+}
+
+// implementation for POST /pubs/import
+// the body is a BibTeX file, a CSV file or a JSON array of publications.
+// Publications that match an existing one by DOI or title are updated,
+// the rest are created.  With ?dryRun=true nothing is saved, the report
+// shows what would have happened
+func (p *PubAPI) ImportPublications(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		format = importFormats[mediaType]
+	}
+	if format == "" {
+		httpkit.AbortWithStatus(c, http.StatusUnsupportedMediaType, "Use ?format= or a Content-Type of application/x-bibtex, text/csv or application/json")
+		return
+	}
+
+	dryRun := false
+	if s := c.Query("dryRun"); s != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(s); err != nil {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "dryRun must be true or false")
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportBytes))
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		httpkit.AbortWithStatus(c, http.StatusRequestEntityTooLarge, "Imports can be at most "+strconv.Itoa(MaxImportBytes>>20)+" MB")
+		return
+	}
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Could not read the import: "+err.Error())
+		return
+	}
+
+	incoming, err := pubschema.ParseImport(format, bytes.NewReader(body))
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Could not parse import: "+err.Error())
+		return
+	}
+
+	existing, err := p.getAllFromRedis()
+	if err != nil {
+		httpkit.AbortWithError(c, err)
+		return
+	}
+
+	report := pubschema.PlanImport(existing, incoming)
+	report.DryRun = dryRun
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	for _, res := range report.Results {
+		if res.Publication == nil {
+			continue
+		}
+		if _, err := p.helper.JSONSet(redisKeyFromId(res.ID), ".", res.Publication); err != nil {
+			//earlier publications in the import were already saved, the
+			//report says which ones
+			slog.ErrorContext(c.Request.Context(), "Error saving publication during import", "id", res.ID, "error", err)
+			httpkit.AbortWithProblem(c, httpkit.NewProblem(http.StatusInternalServerError,
+				"Could not save publication "+strconv.Itoa(res.ID)).With("report", report))
+			return
+		}
+		p.invalidate(c.Request.Context(), res.ID)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
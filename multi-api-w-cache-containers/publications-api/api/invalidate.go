@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+)
+
+// invalidateTimeout is how long the reading list API gets to drop a
+// publication from its cache
+const invalidateTimeout = 2 * time.Second
+
+// cacheInvalidator tells the reading list API when a publication changes,
+// so it stops serving its cached copy right away instead of when the
+// copy expires
+type cacheInvalidator struct {
+	url    string //base URL of the reading list API
+	token  string //the reading list API's cache token
+	client *http.Client
+}
+
+// SetCacheInvalidation turns on calls to DELETE /cache/pubs/:id on the
+// reading list API at url after every change to a publication.  The token
+// has to match the one the reading list API was started with.  An empty
+// url turns the calls off
+func (p *PubAPI) SetCacheInvalidation(url, token string) error {
+	if url == "" {
+		p.invalidator = nil
+		return nil
+	}
+	if token == "" {
+		return fmt.Errorf("a cache token is needed to invalidate publications at %s", url)
+	}
+	p.invalidator = &cacheInvalidator{
+		url:    strings.TrimSuffix(url, "/"),
+		token:  token,
+		client: &http.Client{Timeout: invalidateTimeout},
+	}
+	return nil
+}
+
+// invalidate drops the publication from the reading list API's cache.  It
+// runs in the background so a slow reading list API does not slow down
+// writes, and failures are only logged since the cached copy still
+// expires on its own
+func (p *PubAPI) invalidate(ctx context.Context, id int) {
+	if p.invalidator == nil {
+		return
+	}
+	go p.invalidator.send(context.WithoutCancel(ctx), id)
+}
+
+func (ci *cacheInvalidator) send(ctx context.Context, id int) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, ci.url+"/cache/pubs/"+strconv.Itoa(id), nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build cache invalidation", "id", id, "error", err)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+ci.token)
+	if requestID := httpkit.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(httpkit.RequestIDHeader, requestID)
+	}
+
+	resp, err := ci.client.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "Could not invalidate cached publication", "id", id, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		slog.WarnContext(ctx, "Could not invalidate cached publication", "id", id, "status", resp.StatusCode)
+	}
+}
@@ -2,14 +2,35 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 
+	"architectingsoftware.com/httpkit"
 	"architectingsoftware.com/pub-api/schema"
+	"architectingsoftware.com/pubschema"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
+	"github.com/nitishm/go-rejson/v4/rjs"
+)
+
+const (
+	RedisKeyPrefix = "pubs:"
+
+	//PubMaxAge is how many seconds clients may cache a publication for
+	//before checking back with its ETag
+	PubMaxAge = 60
+
+	//MaxPubBytes is the largest body POST, PUT and PATCH take, a
+	//publication is a few KB even with a long abstract
+	MaxPubBytes = 64 << 10
 )
 
 type cache struct {
@@ -20,15 +41,14 @@ type cache struct {
 
 type PubAPI struct {
 	cache
+	invalidator *cacheInvalidator //nil when the reading list API is not told about changes
 }
 
-func NewPubAPI(location string) (*PubAPI, error) {
+func NewPubAPI(redisOpts *redis.Options) (*PubAPI, error) {
 
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+	//Connect to redis.  The options come from the cache settings, see
+	//config.Cache in httpkit
+	client := redis.NewClient(redisOpts)
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -38,7 +58,7 @@ func NewPubAPI(location string) (*PubAPI, error) {
 	//is working
 	err := client.Ping(ctx).Err()
 	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
+		slog.Error("Error connecting to redis", "error", err)
 		return nil, err
 	}
 
@@ -61,70 +81,299 @@ func NewPubAPI(location string) (*PubAPI, error) {
 	}, nil
 }
 
+// Close closes the connection to redis, it is called when the API shuts
+// down
+func (p *PubAPI) Close() error {
+	return p.client.Close()
+}
+
 func (p *PubAPI) GetPublication(c *gin.Context) {
 
 	pubid := c.Param("id")
 	if pubid == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "No publication ID provided")
 		return
 	}
 
-	cacheKey := "pubs:" + pubid
-	pubBytes, err := p.helper.JSONGet(cacheKey, ".")
+	cacheKey := RedisKeyPrefix + pubid
+	pubBytes, err := p.getBytesFromRedis(cacheKey)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find publication in cache with id=" + cacheKey})
+		httpkit.AbortWithError(c, err)
 		return
 	}
 
 	var pub schema.Publication
-	err = json.Unmarshal(pubBytes.([]byte), &pub)
+	err = json.Unmarshal(pubBytes, &pub)
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusInternalServerError, "cached data seems to be wrong type")
+		return
+	}
+
+	//Citations can be downloaded as BibTeX, RIS or CSL-JSON, either with
+	//?format= or with the Accept header
+	format, err := pubschema.NegotiateFormat(c.Query("format"), c.GetHeader("Accept"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cached data seems to be wrong type"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Header("Vary", "Accept")
+
+	//The ETag lets clients that cache publications, like the reading list
+	//API, check if their copy is still current without downloading it again
+	etag := pubETag(pubBytes, format)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("max-age=%d", PubMaxAge))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if format != pubschema.FormatJSON {
+		httpkit.ServeDownload(c, format.ContentType(), "pub-"+pubid+format.FileExt(), func(w io.Writer) error {
+			return pubschema.WriteCitations(w, format, []schema.Publication{pub})
+		})
 		return
 	}
 
 	c.JSON(http.StatusOK, pub)
 }
 
+// pubETag builds a strong ETag from the stored JSON of a publication, each
+// export format gets its own ETag since the bodies are different
+func pubETag(pubBytes []byte, format pubschema.CiteFormat) string {
+	h := sha256.New()
+	h.Write(pubBytes)
+	if format != pubschema.FormatJSON {
+		h.Write([]byte(format))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
 func (p *PubAPI) GetPublications(c *gin.Context) {
 
+	pubList, err := p.getAllFromRedis()
+	if err != nil {
+		httpkit.AbortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pubList)
+}
+
+// Helper to return every publication in redis
+func (p *PubAPI) getAllFromRedis() ([]schema.Publication, error) {
+
 	var pubList []schema.Publication
 	var pubItem schema.Publication
 
 	//Lets query redis for all of the items
-	pattern := "pubs:*"
+	pattern := RedisKeyPrefix + "*"
 	ks, _ := p.client.Keys(p.context, pattern).Result()
 	for _, key := range ks {
 		err := p.getItemFromRedis(key, &pubItem)
+		if errors.Is(err, httpkit.ErrNotFound) {
+			//deleted since we got the keys
+			continue
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find publication in cache with id=" + key})
-			return
+			return nil, fmt.Errorf("could not load publication %s: %w", key, err)
 		}
 		pubList = append(pubList, pubItem)
 	}
 
-	c.JSON(http.StatusOK, pubList)
+	return pubList, nil
 }
 
-// Helper to return a ToDoItem from redis provided a key
-func (p *PubAPI) getItemFromRedis(key string, pub *schema.Publication) error {
+// Helper to return the JSON stored under a key, a missing key is
+// httpkit.ErrNotFound
+func (p *PubAPI) getBytesFromRedis(key string) ([]byte, error) {
 
 	//Lets query redis for the item, note we can return parts of the
 	//json structure, the second parameter "." means return the entire
 	//json structure
 	itemObject, err := p.helper.JSONGet(key, ".")
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("publication %s: %w", key, httpkit.ErrNotFound)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	//JSONGet returns an "any" object, or empty interface,
 	//we need to convert it to a byte array, which is the
-	//underlying type of the object, then we can unmarshal
-	//it into our ToDoItem struct
-	err = json.Unmarshal(itemObject.([]byte), pub)
+	//underlying type of the object
+	return itemObject.([]byte), nil
+}
+
+// Helper to return a publication from redis provided a key
+func (p *PubAPI) getItemFromRedis(key string, pub *schema.Publication) error {
+	itemBytes, err := p.getBytesFromRedis(key)
 	if err != nil {
 		return err
 	}
+	return json.Unmarshal(itemBytes, pub)
+}
+
+// implementation for POST /pubs
+// adds a new publication, the id in the body must not already be used
+func (p *PubAPI) AddPublication(c *gin.Context) {
+	var pub schema.Publication
+	if !bindPublication(c, &pub) {
+		return
+	}
+
+	pub.Normalize()
+	if err := pub.Validate(); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	//NX only sets the key if it is not there, so two requests adding the
+	//same id can't both win.  redis answers nil when the key was there
+	cacheKey := redisKeyFromId(pub.ID)
+	res, err := p.helper.JSONSet(cacheKey, ".", pub, rjs.SetOptionNX)
+	if err != nil {
+		httpkit.AbortWithError(c, fmt.Errorf("could not save publication: %w", err))
+		return
+	}
+	if res == nil {
+		httpkit.AbortWithError(c, fmt.Errorf("publication %s: %w", cacheKey, httpkit.ErrConflict))
+		return
+	}
+	//the reading list API could still have a copy of a publication that
+	//was deleted and is now added again
+	p.invalidate(c.Request.Context(), pub.ID)
+
+	c.JSON(http.StatusCreated, pub)
+}
+
+// implementation for PUT /pubs/:id
+// replaces an existing publication with the one in the body
+func (p *PubAPI) UpdatePublication(c *gin.Context) {
+	id, ok := pubIdFromParam(c)
+	if !ok {
+		return
+	}
+
+	cacheKey := redisKeyFromId(id)
+	var existing schema.Publication
+	if err := p.getItemFromRedis(cacheKey, &existing); err != nil {
+		httpkit.AbortWithError(c, err)
+		return
+	}
+
+	var pub schema.Publication
+	if !bindPublication(c, &pub) {
+		return
+	}
+
+	p.savePublication(c, id, pub)
+}
+
+// implementation for PATCH /pubs/:id
+// only the fields provided in the body are changed, everything else is
+// kept from the existing publication
+func (p *PubAPI) PatchPublication(c *gin.Context) {
+	id, ok := pubIdFromParam(c)
+	if !ok {
+		return
+	}
+
+	cacheKey := redisKeyFromId(id)
+	var pub schema.Publication
+	if err := p.getItemFromRedis(cacheKey, &pub); err != nil {
+		httpkit.AbortWithError(c, err)
+		return
+	}
+
+	//Unmarshalling into the existing publication only overwrites the
+	//fields that are present in the JSON body
+	if !bindPublication(c, &pub) {
+		return
+	}
+
+	p.savePublication(c, id, pub)
+}
+
+// bindPublication reads a body of at most MaxPubBytes into pub.  A body
+// that is too big is a 413 and one that is not a publication is a 400, ok
+// is false when one of them was sent
+func bindPublication(c *gin.Context, pub *schema.Publication) (ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxPubBytes))
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		httpkit.AbortWithStatus(c, http.StatusRequestEntityTooLarge, "A publication can be at most "+strconv.Itoa(MaxPubBytes>>10)+" KB")
+		return false
+	}
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Could not read request body")
+		return false
+	}
+	if err := json.Unmarshal(body, pub); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Invalid publication: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// implementation for DELETE /pubs/:id
+func (p *PubAPI) DeletePublication(c *gin.Context) {
+	id, ok := pubIdFromParam(c)
+	if !ok {
+		return
+	}
+
+	cacheKey := redisKeyFromId(id)
+	numDeleted, err := p.client.Del(p.context, cacheKey).Result()
+	if err != nil {
+		httpkit.AbortWithError(c, fmt.Errorf("could not delete publication: %w", err))
+		return
+	}
+	if numDeleted == 0 {
+		httpkit.AbortWithError(c, fmt.Errorf("publication %s: %w", cacheKey, httpkit.ErrNotFound))
+		return
+	}
+	p.invalidate(c.Request.Context(), id)
+
+	c.Status(http.StatusNoContent)
+}
+
+// savePublication validates and writes a publication that is replacing
+// the one stored under id, the id itself can not be changed
+func (p *PubAPI) savePublication(c *gin.Context, id int, pub schema.Publication) {
+	if pub.ID != 0 && pub.ID != id {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Publication id in body does not match the id in the path")
+		return
+	}
+	pub.ID = id
+
+	pub.Normalize()
+	if err := pub.Validate(); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := p.helper.JSONSet(redisKeyFromId(id), ".", pub); err != nil {
+		httpkit.AbortWithError(c, fmt.Errorf("could not save publication: %w", err))
+		return
+	}
+	p.invalidate(c.Request.Context(), id)
+
+	c.JSON(http.StatusOK, pub)
+}
+
+// pubIdFromParam reads the :id path parameter, if it is not a valid id
+// a 400 is sent and ok is false
+func pubIdFromParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Publication id must be a positive number")
+		return 0, false
+	}
+	return id, true
+}
 
-	return nil
+// In redis, publications are stored with keys that look like pubs:<id>
+func redisKeyFromId(id int) string {
+	return fmt.Sprintf("%s%d", RedisKeyPrefix, id)
 }
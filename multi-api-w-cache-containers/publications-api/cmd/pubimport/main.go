@@ -0,0 +1,160 @@
+package main
+
+// pubimport loads publications from a BibTeX, CSV or JSON file into the
+// publications API using POST /pubs/import.  The file is parsed here first
+// so mistakes in it are reported before anything is sent.  For example:
+//
+//	go run ./cmd/pubimport -dry-run mypubs.bib
+//	go run ./cmd/pubimport -pubapi http://localhost:2080 mypubs.csv
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"architectingsoftware.com/pubschema"
+)
+
+var (
+	pubAPIURL  string
+	formatFlag string
+	dryRunFlag bool
+	jsonFlag   bool
+)
+
+func processCmdLineFlags() {
+	flag.StringVar(&pubAPIURL, "pubapi", "http://localhost:2080", "Endpoint for the publication API, or set PUBAPI_URL")
+	flag.StringVar(&formatFlag, "format", "", "bibtex, csv or json, the default comes from the file extension")
+	flag.BoolVar(&dryRunFlag, "dry-run", false, "Report what would be created or updated without saving anything")
+	flag.BoolVar(&jsonFlag, "json", false, "Print the import report as JSON")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file | ->\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if envVal := os.Getenv("PUBAPI_URL"); envVal != "" {
+		pubAPIURL = envVal
+	}
+}
+
+// formatFromFileName picks the import format from the file extension
+func formatFromFileName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".bib", ".bibtex":
+		return "bibtex"
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	}
+	return ""
+}
+
+func main() {
+	processCmdLineFlags()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fileName := flag.Arg(0)
+	format := formatFlag
+	if format == "" {
+		format = formatFromFileName(fileName)
+	}
+	if format == "" {
+		fmt.Fprintln(os.Stderr, "Can't tell the format of", fileName, "use -format")
+		os.Exit(2)
+	}
+
+	var in io.Reader = os.Stdin
+	if fileName != "-" {
+		f, err := os.Open(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening file:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	pubs, err := pubschema.ParseImport(format, in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error parsing file:", err)
+		os.Exit(1)
+	}
+
+	report, err := sendImport(pubs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error importing publications:", err)
+		os.Exit(1)
+	}
+
+	if jsonFlag {
+		b, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+	printReport(report)
+}
+
+// sendImport posts the parsed publications as JSON, the API does the
+// matching against the publications it already has
+func sendImport(pubs []pubschema.Publication) (pubschema.ImportReport, error) {
+	var report pubschema.ImportReport
+
+	body, err := json.Marshal(pubs)
+	if err != nil {
+		return report, err
+	}
+
+	url := fmt.Sprintf("%s/pubs/import?dryRun=%t", strings.TrimSuffix(pubAPIURL, "/"), dryRunFlag)
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return report, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if err := json.Unmarshal(respBody, &report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func printReport(report pubschema.ImportReport) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tID\tMATCHED BY\tTITLE")
+	for _, res := range report.Results {
+		title := res.Title
+		if res.Error != "" {
+			title += " (" + res.Error + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", res.Action, res.ID, res.MatchedBy, title)
+	}
+	tw.Flush()
+
+	prefix := ""
+	if report.DryRun {
+		prefix = "Dry run, nothing was saved.  "
+	}
+	fmt.Printf("\n%s%d created, %d updated, %d unchanged, %d skipped\n",
+		prefix, report.Created, report.Updated, report.Unchanged, report.Skipped)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+
+	"architectingsoftware.com/httpkit/config"
+)
+
+// Config is every setting of the publications API, see the config package
+// in httpkit for how the defaults, a file, the environment and the flags
+// are layered
+type Config struct {
+	Server     config.Server    `yaml:"server" toml:"server"`
+	Cache      config.Cache     `yaml:"cache" toml:"cache"`
+	CORS       config.CORS      `yaml:"cors" toml:"cors"`
+	Invalidate InvalidateConfig `yaml:"invalidate" toml:"invalidate"`
+	Log        config.Log       `yaml:"log" toml:"log"`
+	RateLimit  config.RateLimit `yaml:"rateLimit" toml:"rateLimit"`
+}
+
+// InvalidateConfig is the reading list API that is told when a
+// publication changes, so it can drop its cached copy
+type InvalidateConfig struct {
+	URL   string `yaml:"url" toml:"url"`
+	Token string `yaml:"token" toml:"token"`
+}
+
+// DefaultConfig is good for running the API on a laptop next to a redis
+// container
+func DefaultConfig() *Config {
+	return &Config{
+		Server:    config.DefaultServer(2080),
+		Cache:     config.Cache{Addr: "0.0.0.0:6379"},
+		CORS:      config.DefaultCORS(),
+		Log:       config.DefaultLog(),
+		RateLimit: config.DefaultRateLimit(),
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+	c.Cache.BindFlags(fs, "c", "cache")
+	c.CORS.BindFlags(fs)
+	fs.StringVar(&c.Invalidate.URL, "invalidate-url", c.Invalidate.URL, "Reading list API to tell when a publication changes, like http://localhost:3080")
+	fs.StringVar(&c.Invalidate.Token, "invalidate-token", c.Invalidate.Token, "Cache token of the reading list API, prefer PUBAPI_INVALIDATE_TOKEN")
+	c.Log.BindFlags(fs)
+	c.RateLimit.BindFlags(fs)
+}
+
+func (c *Config) Settings() []config.Setting {
+	settings := config.ServerSettings("PUBAPI_")
+	settings = append(settings, config.CacheSettings("c", "cache", "PUBAPI_CACHE_")...)
+	settings = append(settings, config.CORSSettings("PUBAPI_")...)
+	settings = append(settings,
+		config.Setting{Flag: "invalidate-url", Env: "PUBAPI_INVALIDATE_URL"},
+		config.Setting{Flag: "invalidate-token", Env: "PUBAPI_INVALIDATE_TOKEN"},
+	)
+	settings = append(settings, config.LogSettings("PUBAPI_")...)
+	return append(settings, config.RateLimitSettings("PUBAPI_")...)
+}
+
+func (c *Config) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	c.Cache.Check(&p)
+	c.CORS.Check(&p)
+	//the reading list API is only told about changes when the url is set,
+	//main logs a warning if the token is missing
+	if c.Invalidate.URL != "" {
+		config.CheckURL(&p, "invalidate url", c.Invalidate.URL)
+	}
+	c.Log.Check(&p)
+	c.RateLimit.Check(&p)
+	return p.Err()
+}
+
+// String is the config as YAML without the token and the cache password,
+// it is logged when the API starts
+func (c *Config) String() string {
+	r := *c
+	r.Cache = r.Cache.Redacted()
+	r.Invalidate.Token = config.Redact(r.Invalidate.Token)
+	return config.String(r)
+}
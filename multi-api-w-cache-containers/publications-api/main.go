@@ -1,72 +1,178 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/httpkit/config"
 	"architectingsoftware.com/pub-api/api"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag string
-	portFlag uint
-	cacheURL string
-)
+func main() {
+	//the defaults, a config file, the environment and the command line
+	//flags, see config.go
+	cfg := DefaultConfig()
+	if err := config.Load(cfg, os.Args[1:], "PUBAPI_CONFIG"); err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
+	}
+	accessLogger, accessLevel, err := setupLogging(cfg.Log)
+	if err != nil {
+		fmt.Println("Error setting up logging:", err)
+		os.Exit(1)
+	}
+	slog.Info("Init", "config", cfg.String())
 
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.StringVar(&cacheURL, "c", "0.0.0.0:6379", "Default cache location")
-	flag.UintVar(&portFlag, "p", 2080, "Default Port")
+	redisOpts, err := cfg.Cache.RedisOptions()
+	if err != nil {
+		fmt.Println("Error setting up redis:", err)
+		os.Exit(1)
+	}
+	apiHandler, err := api.NewPubAPI(redisOpts)
 
-	flag.Parse()
-}
+	if err != nil {
+		panic(err)
+	}
 
-func envVarOrDefault(envVar string, defaultVal string) string {
-	envVal := os.Getenv(envVar)
-	if envVal != "" {
-		return envVal
+	//the reading list API caches publications, tell it when one changes.
+	//Without a token its cached copies just expire on their own
+	if err := apiHandler.SetCacheInvalidation(cfg.Invalidate.URL, cfg.Invalidate.Token); err != nil {
+		slog.Warn("Not invalidating cached publications", "error", err)
 	}
-	return defaultVal
-}
 
-func setupParms() {
-	//first process any command line flags
-	processCmdLineFlags()
+	//gin.Default() with our own access log, every line has the request
+	//id so it can be matched up with the lines from the other API
+	r := gin.New()
+	r.Use(httpkit.RequestID())
+	if accessLogger != nil {
+		r.Use(httpkit.AccessLog(accessLogger, accessLevel))
+	}
+	//browsers can call the API from -cors-origins, and can read the ETag
+	//so they can send it back in If-None-Match
+	corsCfg := httpkit.CORSConfig(cfg.CORS.Origins)
+	corsCfg.AllowHeaders = append(corsCfg.AllowHeaders, "If-None-Match")
+	corsCfg.ExposeHeaders = append(corsCfg.ExposeHeaders, "ETag")
+	r.Use(gin.Recovery(), cors.New(corsCfg))
+	r.NoRoute(httpkit.NoRoute)
+
+	//every caller gets a bucket of requests for each route, see httpkit.
+	//It runs before any handler so callers that are turned away, like
+	//ones with a bad token, are counted too
+	rateStore, err := httpkit.NewRateStore(cfg.RateLimit.Store, redisOpts)
+	if err != nil {
+		fmt.Println("Error setting up rate limiting:", err)
+		os.Exit(1)
+	}
+	var limiter *httpkit.RateLimiter
+	if rateStore != nil {
+		limiter = httpkit.NewRateLimiter(rateStore, httpkit.PerMinute(cfg.RateLimit.Default))
+		r.Use(limiter.Handler(httpkit.Limit{}))
+	}
 
-	//now process any environment variables
-	cacheURL = envVarOrDefault("PUBAPI_CACHE_URL", cacheURL)
-	hostFlag = envVarOrDefault("PUBAPI_HOST", hostFlag)
-	pfNew, err := strconv.Atoi(envVarOrDefault("PUBAPI_PORT", fmt.Sprintf("%d", portFlag)))
-	//only update the port if we were able to convert the env var to an int, else
-	//we will use the default we got from the command line, or command line defaults
-	if err == nil {
-		portFlag = uint(pfNew)
+	r.GET("/pubs", apiHandler.GetPublications)
+	r.GET("/pubs/:id", apiHandler.GetPublication)
+	r.POST("/pubs", apiHandler.AddPublication)
+	r.POST("/pubs/import", apiHandler.ImportPublications)
+	r.PUT("/pubs/:id", apiHandler.UpdatePublication)
+	r.PATCH("/pubs/:id", apiHandler.PatchPublication)
+	r.DELETE("/pubs/:id", apiHandler.DeletePublication)
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+	if err := serve(srv, cfg.Server.ShutdownGrace.Std()); err != nil {
+		slog.Error("Server stopped with an error", "error", err)
 	}
 
+	//The server has stopped so nothing is using redis anymore
+	if limiter != nil {
+		if err := limiter.Close(); err != nil {
+			slog.Error("Error closing rate limiter", "error", err)
+		}
+	}
+	if err := apiHandler.Close(); err != nil {
+		slog.Error("Error closing redis", "error", err)
+	}
+	slog.Info("Stopped")
 }
 
-func main() {
-	//this will allow the user to override key parameters and also setup defaults
-	setupParms()
-
-	apiHandler, err := api.NewPubAPI(cacheURL)
-
+// setupLogging makes the JSON or text logger the default for slog and for
+// the log package, and builds the access logger.  The access logger is
+// nil when the access log is off.  It is not filtered by the log level,
+// its lines are never below accessLevel so every request is logged
+func setupLogging(logCfg config.Log) (accessLogger *slog.Logger, accessLevel slog.Level, err error) {
+	level, err := httpkit.ParseLogLevel(logCfg.Level)
 	if err != nil {
-		panic(err)
+		return nil, 0, err
 	}
+	logger, err := httpkit.NewLogger(os.Stdout, logCfg.Format, level)
+	if err != nil {
+		return nil, 0, err
+	}
+	slog.SetDefault(logger)
 
-	r := gin.Default()
-	r.Use(cors.Default())
+	//gin prints every route when it is in debug mode, that is only
+	//wanted when we are debugging
+	if level > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-	r.GET("/pubs", apiHandler.GetPublications)
-	r.GET("/pubs/:id", apiHandler.GetPublication)
+	accessLevel, err = httpkit.ParseLogLevel(logCfg.AccessLevel)
+	if err != nil {
+		return nil, 0, fmt.Errorf("access log: %w", err)
+	}
+	if logCfg.AccessFormat == "off" {
+		return nil, accessLevel, nil
+	}
+	accessLogger, err = httpkit.NewLogger(os.Stdout, logCfg.AccessFormat, accessLevel)
+	if err != nil {
+		return nil, 0, fmt.Errorf("access log: %w", err)
+	}
+	return accessLogger, accessLevel, nil
+}
 
-	//For now we will just support gets
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+// serve runs the server until it gets SIGINT or SIGTERM, which is what
+// docker and kubernetes send to stop a container.  It then stops taking
+// new connections and gives the requests that are running the grace
+// period to finish
+func serve(srv *http.Server, grace time.Duration) error {
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		//the server could not start, for example the port is in use
+		return err
+	case <-stopCtx.Done():
+	}
 
+	slog.Info("Shutting down, waiting for requests to finish", "grace", grace)
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("requests did not finish in time: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
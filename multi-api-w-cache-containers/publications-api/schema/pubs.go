@@ -1,15 +1,8 @@
 package schema
 
-type slideLink struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
-	Link        string `json:"link"`
-}
-type Publication struct {
-	ID       int         `json:"id"`
-	Title    string      `json:"title"`
-	Cite     string      `json:"cite"`
-	Link     string      `json:"link,omitempty"`
-	Slides   []slideLink `json:"slides,omitempty"`
-	Abstract string      `json:"abstract"`
-}
+import "architectingsoftware.com/pubschema"
+
+// The publication types are shared with the reading list API, see the
+// pubschema module.  Normalize and Validate are defined there too
+type Publication = pubschema.Publication
+type SlideLink = pubschema.SlideLink
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/pub-api/api"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis answers the few commands the publication writes send, so the
+// handlers can be tested without a redis that has the JSON module
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func startFakeRedis(t *testing.T) *redis.Options {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeRedis{data: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return &redis.Options{Addr: ln.Addr().String()}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, f.reply(args))
+	}
+}
+
+// readCommand reads one command, which redis clients send as an array of
+// bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) reply(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "JSON.SET":
+		_, exists := f.data[args[1]]
+		if exists && len(args) > 4 && strings.ToUpper(args[4]) == "NX" {
+			return "$-1\r\n"
+		}
+		f.data[args[1]] = args[3]
+		return "+OK\r\n"
+	case "JSON.GET":
+		v, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	default:
+		return "-ERR unknown command " + args[0] + "\r\n"
+	}
+}
+
+func newRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	pubAPI, err := api.NewPubAPI(startFakeRedis(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { pubAPI.Close() })
+
+	r := gin.New()
+	r.POST("/pubs", pubAPI.AddPublication)
+	r.PUT("/pubs/:id", pubAPI.UpdatePublication)
+	r.PATCH("/pubs/:id", pubAPI.PatchPublication)
+	return r
+}
+
+func send(r *gin.Engine, method, path, body string) (*httptest.ResponseRecorder, httpkit.Problem) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	var p httpkit.Problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	return w, p
+}
+
+func TestAddPublicationConflict(t *testing.T) {
+	r := newRouter(t)
+	body := `{"id": 7, "title": "Architecting Software"}`
+
+	w, _ := send(r, http.MethodPost, "/pubs", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	//the second add of the same id is turned away by redis, not by a read
+	//before the write
+	w, p := send(r, http.MethodPost, "/pubs", `{"id": 7, "title": "Something Else"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "publication pubs:7: already exists", p.Detail)
+
+	w, _ = send(r, http.MethodPatch, "/pubs/7", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Architecting Software")
+}
+
+func TestWritesLimitTheBody(t *testing.T) {
+	r := newRouter(t)
+	w, _ := send(r, http.MethodPost, "/pubs", `{"id": 7, "title": "Architecting Software"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	big := `{"id": 7, "title": "Architecting Software", "abstract": "` + strings.Repeat("a", api.MaxPubBytes) + `"}`
+	writes := map[string]string{
+		http.MethodPost:  "/pubs",
+		http.MethodPut:   "/pubs/7",
+		http.MethodPatch: "/pubs/7",
+	}
+	for method, path := range writes {
+		w, p := send(r, method, path, big)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, method)
+		assert.Equal(t, "A publication can be at most 64 KB", p.Detail, method)
+	}
+
+	w, p := send(r, http.MethodPatch, "/pubs/7", `{"title": 7}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, p.Detail, "Invalid publication")
+}
@@ -0,0 +1,294 @@
+package pubschema
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseBibTeX reads every entry in a BibTeX file and turns it into a
+// publication.  BibTeX entries do not have our ids, so ID is left as 0.
+// @string macros are not expanded, a value that uses one is kept as the
+// macro name.  Files written by WriteCitations can be read back in
+func ParseBibTeX(r io.Reader) ([]Publication, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &bibParser{src: string(b)}
+	pubs := []Publication{}
+	for {
+		at := strings.IndexByte(p.src[p.pos:], '@')
+		if at < 0 {
+			return pubs, nil
+		}
+		p.pos += at + 1
+
+		entryType := strings.ToLower(p.readIdent())
+		p.skipSpace()
+		if p.done() || (p.peek() != '{' && p.peek() != '(') {
+			return nil, p.errorf("expected { after @%s", entryType)
+		}
+		closer := byte('}')
+		if p.peek() == '(' {
+			closer = ')'
+		}
+		p.pos++
+
+		switch entryType {
+		case "comment", "preamble", "string":
+			if err := p.skipEntry(closer); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fields, err := p.readEntry(closer)
+		if err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pubFromBibFields(fields))
+	}
+}
+
+type bibParser struct {
+	src string
+	pos int
+}
+
+func (p *bibParser) done() bool { return p.pos >= len(p.src) }
+func (p *bibParser) peek() byte { return p.src[p.pos] }
+
+func (p *bibParser) errorf(format string, args ...interface{}) error {
+	end := p.pos
+	if end > len(p.src) {
+		end = len(p.src)
+	}
+	line := strings.Count(p.src[:end], "\n") + 1
+	return fmt.Errorf("bibtex line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *bibParser) skipSpace() {
+	for !p.done() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+		p.pos++
+	}
+}
+
+// readIdent reads an entry type, key, field name or bare value
+func (p *bibParser) readIdent() string {
+	start := p.pos
+	for !p.done() && strings.IndexByte(" \t\r\n{}(),=#\"", p.peek()) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// skipEntry skips over an entry that we don't use, like @comment
+func (p *bibParser) skipEntry(closer byte) error {
+	depth := 1
+	for ; !p.done(); p.pos++ {
+		switch p.peek() {
+		case '{', '(':
+			depth++
+		case '}', ')':
+			depth--
+		}
+		if depth == 0 && p.peek() == closer {
+			p.pos++
+			return nil
+		}
+	}
+	return p.errorf("entry is not closed")
+}
+
+// readEntry reads the key and all of the fields of an entry, field names
+// are lower cased
+func (p *bibParser) readEntry(closer byte) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	p.skipSpace()
+	p.readIdent() //the cite key, we make our own
+	p.skipSpace()
+
+	for {
+		if p.done() {
+			return nil, p.errorf("entry is not closed")
+		}
+		switch p.peek() {
+		case closer:
+			p.pos++
+			return fields, nil
+		case ',':
+			p.pos++
+			p.skipSpace()
+			continue
+		}
+
+		name := strings.ToLower(p.readIdent())
+		if name == "" {
+			return nil, p.errorf("expected a field name")
+		}
+		p.skipSpace()
+		if p.done() || p.peek() != '=' {
+			return nil, p.errorf("expected = after %s", name)
+		}
+		p.pos++
+		p.skipSpace()
+
+		value, err := p.readValue()
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = value
+		p.skipSpace()
+	}
+}
+
+// readValue reads a field value, which is a {braced} or "quoted" string
+// or a bare word, and may be several of those joined with #
+func (p *bibParser) readValue() (string, error) {
+	var sb strings.Builder
+	for {
+		if p.done() {
+			return "", p.errorf("expected a value")
+		}
+		switch p.peek() {
+		case '{':
+			s, err := p.readDelimited('}')
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(s)
+		case '"':
+			s, err := p.readDelimited('"')
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(s)
+		default:
+			sb.WriteString(p.readIdent())
+		}
+
+		p.skipSpace()
+		if p.done() || p.peek() != '#' {
+			return sb.String(), nil
+		}
+		p.pos++
+		p.skipSpace()
+	}
+}
+
+// readDelimited reads from the opening { or " to the matching end, braces
+// inside are kept so cleanBibValue can tell them from escaped ones
+func (p *bibParser) readDelimited(end byte) (string, error) {
+	p.pos++
+	start := p.pos
+	depth := 0
+	for ; !p.done(); p.pos++ {
+		c := p.peek()
+		switch {
+		case c == '\\':
+			p.pos++ //skip whatever is escaped
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == end && depth == 0:
+			s := p.src[start:p.pos]
+			p.pos++
+			return s, nil
+		}
+	}
+	return "", p.errorf("value is not closed")
+}
+
+var bibtexUnescaper = strings.NewReplacer(
+	`\textbackslash{}`, "\x00",
+	`\textasciitilde{}`, "~",
+	`\textasciicircum{}`, "^",
+	`\&`, "&",
+	`\%`, "%",
+	`\$`, "$",
+	`\#`, "#",
+	`\_`, "_",
+	`\{`, "\x01",
+	`\}`, "\x02",
+)
+
+var bibtexRestorer = strings.NewReplacer("\x00", `\`, "\x01", "{", "\x02", "}")
+
+// cleanBibValue undoes the escaping done by WriteCitations, drops the
+// braces that are only there to protect case, and collapses white space
+func cleanBibValue(s string) string {
+	s = bibtexUnescaper.Replace(s)
+	s = strings.NewReplacer("{", "", "}", "").Replace(s)
+	s = bibtexRestorer.Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func pubFromBibFields(fields map[string]string) Publication {
+	get := func(name string) string {
+		return cleanBibValue(fields[name])
+	}
+
+	pub := Publication{
+		Title:    get("title"),
+		Link:     get("url"),
+		Abstract: get("abstract"),
+		DOI:      get("doi"),
+	}
+	if year, err := strconv.Atoi(get("year")); err == nil {
+		pub.Year = year
+	}
+
+	if authors := get("author"); authors != "" {
+		for _, a := range strings.Split(authors, " and ") {
+			//BibTeX names can be written "Last, First"
+			if last, first, ok := strings.Cut(a, ","); ok {
+				a = strings.TrimSpace(first) + " " + strings.TrimSpace(last)
+			}
+			pub.Authors = append(pub.Authors, strings.TrimSpace(a))
+		}
+	}
+
+	//WriteCitations puts our free text cite in howpublished, otherwise we
+	//build one that looks like the ones in the sample data
+	pub.Cite = get("howpublished")
+	if pub.Cite == "" {
+		pub.Cite = buildCite(pub.Authors, get)
+	}
+	return pub
+}
+
+func buildCite(authors []string, get func(string) string) string {
+	parts := []string{}
+	for _, venue := range []string{"journal", "booktitle", "school", "institution", "publisher"} {
+		if v := get(venue); v != "" {
+			parts = append(parts, "In "+v)
+			break
+		}
+	}
+	if v := get("volume"); v != "" {
+		parts = append(parts, "Volume "+v)
+	}
+	if v := get("number"); v != "" {
+		parts = append(parts, "Number "+v)
+	}
+	if v := get("year"); v != "" {
+		parts = append(parts, v)
+	}
+	if v := get("pages"); v != "" {
+		parts = append(parts, "pp. "+strings.ReplaceAll(v, "--", "-"))
+	}
+
+	cite := strings.Join(authors, ", ")
+	if len(parts) > 0 {
+		if cite != "" {
+			cite += ". "
+		}
+		cite += strings.Join(parts, ", ") + "."
+	}
+	return cite
+}
@@ -0,0 +1,353 @@
+package pubschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CiteFormat is a format that publications can be exported in, other
+// than the JSON that the APIs normally return
+type CiteFormat string
+
+const (
+	FormatJSON   CiteFormat = "json"
+	FormatBibTeX CiteFormat = "bibtex"
+	FormatRIS    CiteFormat = "ris"
+	FormatCSL    CiteFormat = "csl"
+)
+
+// formatNames are the values accepted by ?format=
+var formatNames = map[string]CiteFormat{
+	"json":     FormatJSON,
+	"bibtex":   FormatBibTeX,
+	"bib":      FormatBibTeX,
+	"ris":      FormatRIS,
+	"csl":      FormatCSL,
+	"csl-json": FormatCSL,
+	"csljson":  FormatCSL,
+}
+
+// mediaTypes are the values accepted in an Accept header
+var mediaTypes = map[string]CiteFormat{
+	"*/*":                                 FormatJSON,
+	"application/*":                       FormatJSON,
+	"application/json":                    FormatJSON,
+	"application/x-bibtex":                FormatBibTeX,
+	"text/x-bibtex":                       FormatBibTeX,
+	"application/x-research-info-systems": FormatRIS,
+	"application/vnd.citationstyles.csl+json": FormatCSL,
+}
+
+// NegotiateFormat picks the format for a response.  The format query
+// parameter wins if it is provided, otherwise the Accept header is used.
+// An Accept header that does not ask for anything we know gets JSON,
+// which is what the APIs always returned, only a bad ?format= is an error
+func NegotiateFormat(format, accept string) (CiteFormat, error) {
+	if format != "" {
+		f, ok := formatNames[strings.ToLower(format)]
+		if !ok {
+			return "", fmt.Errorf("unknown format %q, use json, bibtex, ris or csl", format)
+		}
+		return f, nil
+	}
+
+	type choice struct {
+		format CiteFormat
+		q      float64
+	}
+	choices := []choice{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{format: f, q: q})
+		}
+	}
+
+	//the order in the header breaks ties
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return FormatJSON, nil
+	}
+	return choices[0].format, nil
+}
+
+func (f CiteFormat) ContentType() string {
+	switch f {
+	case FormatBibTeX:
+		return "application/x-bibtex; charset=utf-8"
+	case FormatRIS:
+		return "application/x-research-info-systems; charset=utf-8"
+	case FormatCSL:
+		return "application/vnd.citationstyles.csl+json; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// FileExt is the usual file extension for the format, it is used to name
+// the downloaded bibliography
+func (f CiteFormat) FileExt() string {
+	switch f {
+	case FormatBibTeX:
+		return ".bib"
+	case FormatRIS:
+		return ".ris"
+	}
+	return ".json"
+}
+
+// WriteCitations writes all of the publications as one bibliography in
+// the requested format
+func WriteCitations(w io.Writer, f CiteFormat, pubs []Publication) error {
+	switch f {
+	case FormatBibTeX:
+		return writeBibTeX(w, pubs)
+	case FormatRIS:
+		return writeRIS(w, pubs)
+	case FormatCSL:
+		return writeCSL(w, pubs)
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(pubs)
+	}
+	return fmt.Errorf("unknown format %q", f)
+}
+
+var (
+	//"B. S. Mitchell" or "B.S.Mitchell"
+	initialsName = regexp.MustCompile(`^([A-Z]\.\s*)+[A-Z][A-Za-z'\-]+$`)
+	//"Brian S. Mitchell" or "Ansh Chandnani"
+	fullName    = regexp.MustCompile(`^[A-Z][a-z]+(\s+[A-Z]\.)*\s+[A-Z][A-Za-z'\-]+$`)
+	yearPattern = regexp.MustCompile(`\b(19|20)\d{2}\b`)
+
+	//words that show up in the cite right after the authors, and that
+	//would otherwise look like a "First Last" name
+	notNameWords = []string{"Report", "University", "Department", "Proceedings", "Journal", "Conference", "Preprint"}
+)
+
+// CiteAuthors returns the authors of the publication.  Publications
+// created before Authors existed only have the free text Cite, which
+// starts with the authors, so we make a best guess from that
+func (p *Publication) CiteAuthors() []string {
+	if len(p.Authors) > 0 {
+		return p.Authors
+	}
+
+	cite := p.Cite
+	if i := strings.Index(cite, " In "); i >= 0 {
+		cite = cite[:i]
+	}
+
+	authors := []string{}
+	for _, part := range strings.Split(cite, ",") {
+		for _, name := range strings.Split(part, " and ") {
+			name = strings.TrimSpace(name)
+			name = strings.TrimPrefix(name, "and ")
+			name = strings.TrimSuffix(name, ".")
+			if name == "" {
+				continue
+			}
+			if !looksLikeName(name) {
+				return authors
+			}
+			authors = append(authors, name)
+		}
+	}
+	return authors
+}
+
+func looksLikeName(s string) bool {
+	for _, w := range notNameWords {
+		if strings.Contains(s, w) {
+			return false
+		}
+	}
+	return initialsName.MatchString(s) || fullName.MatchString(s)
+}
+
+// CiteYear returns Year, or the last year mentioned in Cite, or 0
+func (p *Publication) CiteYear() int {
+	if p.Year > 0 {
+		return p.Year
+	}
+	years := yearPattern.FindAllString(p.Cite, -1)
+	if len(years) == 0 {
+		return 0
+	}
+	year, _ := strconv.Atoi(years[len(years)-1])
+	return year
+}
+
+// splitName splits a name into given names and the family name, the
+// family name is the last word, "B.S.Mitchell" is split after the last dot
+func splitName(name string) (given, family string) {
+	i := strings.LastIndexAny(name, " .")
+	if i < 0 {
+		return "", name
+	}
+	return strings.TrimSpace(name[:i+1]), name[i+1:]
+}
+
+// CiteKey is the BibTeX key, for example mitchell2008pub10.  The id is
+// included so the keys in a reading list are always unique
+func (p *Publication) CiteKey() string {
+	key := ""
+	if authors := p.CiteAuthors(); len(authors) > 0 {
+		_, family := splitName(authors[0])
+		key = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' {
+				return r
+			}
+			return -1
+		}, strings.ToLower(family))
+	}
+	if year := p.CiteYear(); year > 0 {
+		key += strconv.Itoa(year)
+	}
+	return fmt.Sprintf("%spub%d", key, p.ID)
+}
+
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+func writeBibTeX(w io.Writer, pubs []Publication) error {
+	for i, p := range pubs {
+		p.Title, p.Link = strings.TrimSpace(p.Title), strings.TrimSpace(p.Link)
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		year := ""
+		if y := p.CiteYear(); y > 0 {
+			year = strconv.Itoa(y)
+		}
+		fields := [][2]string{
+			{"author", strings.Join(p.CiteAuthors(), " and ")},
+			//the extra braces keep BibTeX from changing the case of the title
+			{"title", "{" + bibtexEscaper.Replace(p.Title) + "}"},
+			{"year", year},
+			{"howpublished", bibtexEscaper.Replace(p.Cite)},
+			{"doi", bibtexEscaper.Replace(p.DOI)},
+			{"url", p.Link},
+			{"abstract", bibtexEscaper.Replace(p.Abstract)},
+		}
+
+		fmt.Fprintf(w, "@misc{%s,\n", p.CiteKey())
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Fprintf(w, "  %s = {%s},\n", f[0], f[1])
+			}
+		}
+		if _, err := fmt.Fprintln(w, "}"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRIS(w io.Writer, pubs []Publication) error {
+	//RIS is line based so values can't have line breaks in them
+	line := func(tag, value string) {
+		value = strings.Join(strings.Fields(value), " ")
+		if value != "" {
+			fmt.Fprintf(w, "%s  - %s\r\n", tag, value)
+		}
+	}
+
+	for _, p := range pubs {
+		p.Title, p.Link = strings.TrimSpace(p.Title), strings.TrimSpace(p.Link)
+		line("TY", "GEN")
+		line("ID", p.CiteKey())
+		for _, a := range p.CiteAuthors() {
+			line("AU", a)
+		}
+		line("TI", p.Title)
+		if year := p.CiteYear(); year > 0 {
+			line("PY", strconv.Itoa(year))
+		}
+		line("N1", p.Cite)
+		line("DO", p.DOI)
+		line("UR", p.Link)
+		line("AB", p.Abstract)
+		if _, err := fmt.Fprint(w, "ER  - \r\n\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type cslName struct {
+	Family string `json:"family,omitempty"`
+	Given  string `json:"given,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+type cslItem struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Author   []cslName `json:"author,omitempty"`
+	Issued   *cslDate  `json:"issued,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	DOI      string    `json:"DOI,omitempty"`
+	URL      string    `json:"URL,omitempty"`
+	Abstract string    `json:"abstract,omitempty"`
+}
+
+func writeCSL(w io.Writer, pubs []Publication) error {
+	items := make([]cslItem, 0, len(pubs))
+	for _, p := range pubs {
+		p.Title, p.Link = strings.TrimSpace(p.Title), strings.TrimSpace(p.Link)
+		item := cslItem{
+			ID:       p.CiteKey(),
+			Type:     "article",
+			Title:    p.Title,
+			Note:     p.Cite,
+			DOI:      p.DOI,
+			URL:      p.Link,
+			Abstract: p.Abstract,
+		}
+		for _, a := range p.CiteAuthors() {
+			given, family := splitName(a)
+			item.Author = append(item.Author, cslName{Family: family, Given: given})
+		}
+		if year := p.CiteYear(); year > 0 {
+			item.Issued = &cslDate{DateParts: [][]int{{year}}}
+		}
+		items = append(items, item)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
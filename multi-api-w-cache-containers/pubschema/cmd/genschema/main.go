@@ -0,0 +1,22 @@
+package main
+
+// genschema writes the JSON Schema for a publication to stdout.  Run it
+// from the pubschema directory with:
+//
+//	go run ./cmd/genschema > publication.schema.json
+
+import (
+	"fmt"
+	"os"
+
+	"architectingsoftware.com/pubschema"
+)
+
+func main() {
+	b, err := pubschema.PublicationSchemaJson()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error generating schema:", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(b))
+}
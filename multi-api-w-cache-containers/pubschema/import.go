@@ -0,0 +1,307 @@
+package pubschema
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseImport reads publications in one of the import formats: bibtex,
+// csv or json (an array of publications)
+func ParseImport(format string, r io.Reader) ([]Publication, error) {
+	switch strings.ToLower(format) {
+	case "bibtex", "bib":
+		return ParseBibTeX(r)
+	case "csv":
+		return ParseCSV(r)
+	case "json":
+		pubs := []Publication{}
+		if err := json.NewDecoder(r).Decode(&pubs); err != nil {
+			return nil, err
+		}
+		return pubs, nil
+	}
+	return nil, fmt.Errorf("unknown import format %q, use bibtex, csv or json", format)
+}
+
+// csvColumns are the columns a CSV import can have, the header row is
+// required and the column names are not case sensitive
+var csvColumns = []string{"id", "title", "cite", "link", "abstract", "authors", "year", "doi"}
+
+// ParseCSV reads publications from a CSV file with a header row.  Only the
+// title column is required, authors are separated with a semicolon
+func ParseCSV(r io.Reader) ([]Publication, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "url" {
+			name = "link"
+		}
+		known := false
+		for _, c := range csvColumns {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("csv column %q is not one of %s", name, strings.Join(csvColumns, ", "))
+		}
+		cols[name] = i
+	}
+	if _, ok := cols["title"]; !ok {
+		return nil, fmt.Errorf("csv needs a title column")
+	}
+
+	pubs := []Publication{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return pubs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		get := func(name string) string {
+			if i, ok := cols[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		pub := Publication{
+			Title:    get("title"),
+			Cite:     get("cite"),
+			Link:     get("link"),
+			Abstract: get("abstract"),
+			DOI:      get("doi"),
+		}
+		if s := get("id"); s != "" {
+			if pub.ID, err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("csv line %d: id %q is not a number", line, s)
+			}
+		}
+		if s := get("year"); s != "" {
+			if pub.Year, err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("csv line %d: year %q is not a number", line, s)
+			}
+		}
+		for _, a := range strings.Split(get("authors"), ";") {
+			if a = strings.TrimSpace(a); a != "" {
+				pub.Authors = append(pub.Authors, a)
+			}
+		}
+		pubs = append(pubs, pub)
+	}
+}
+
+// ImportAction is what an import does with one of the publications
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+	ImportSkip      ImportAction = "skip" //invalid, or a duplicate within the import
+)
+
+type ImportResult struct {
+	Index       int          `json:"index"` //position in the import, from 0
+	Action      ImportAction `json:"action"`
+	ID          int          `json:"id,omitempty"`
+	Title       string       `json:"title"`
+	MatchedBy   string       `json:"matchedBy,omitempty"` //doi or title
+	Error       string       `json:"error,omitempty"`
+	Publication *Publication `json:"publication,omitempty"` //what will be saved
+}
+
+type ImportReport struct {
+	DryRun    bool           `json:"dryRun"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Skipped   int            `json:"skipped"`
+	Results   []ImportResult `json:"results"`
+}
+
+// PlanImport works out what importing the incoming publications would do
+// without changing anything.  An incoming publication is the same as an
+// existing one if the DOIs match, or if there is no DOI match and the
+// titles match once case, punctuation and spacing are ignored.  Matches
+// are updated with the non empty fields of the incoming publication, and
+// keep their id.  New publications without an id get the next multiple of
+// 10 after the highest id, like the sample data
+func PlanImport(existing, incoming []Publication) ImportReport {
+	pubs := append([]Publication{}, existing...)
+	byDOI := make(map[string]int)
+	byTitle := make(map[string]int)
+	usedIDs := make(map[int]bool)
+	maxID := 0
+
+	index := func(i int) {
+		p := pubs[i]
+		if k := doiKey(p.DOI); k != "" {
+			byDOI[k] = i
+		}
+		if k := titleKey(p.Title); k != "" {
+			byTitle[k] = i
+		}
+		usedIDs[p.ID] = true
+		if p.ID > maxID {
+			maxID = p.ID
+		}
+	}
+	for i := range pubs {
+		//compare against the normalized publications so stray spaces in
+		//the stored data don't turn every match into an update.  Slides is
+		//copied first since Normalize changes it in place
+		pubs[i].Slides = append([]SlideLink(nil), pubs[i].Slides...)
+		pubs[i].Normalize()
+		index(i)
+	}
+
+	//which incoming publication created or updated each publication, so
+	//duplicates within the import are caught
+	touchedBy := make(map[int]int)
+
+	report := ImportReport{Results: []ImportResult{}}
+	for n, in := range incoming {
+		in.Normalize()
+		res := ImportResult{Index: n, Title: in.Title}
+
+		match := -1
+		if j, ok := byDOI[doiKey(in.DOI)]; ok && doiKey(in.DOI) != "" {
+			match, res.MatchedBy = j, "doi"
+		} else if j, ok := byTitle[titleKey(in.Title)]; ok && titleKey(in.Title) != "" {
+			match, res.MatchedBy = j, "title"
+		}
+
+		var pub Publication
+		switch {
+		case match >= 0 && hasKey(touchedBy, match):
+			res.Action = ImportSkip
+			res.ID = pubs[match].ID
+			res.Error = fmt.Sprintf("duplicate of entry %d in this import", touchedBy[match])
+		case match >= 0:
+			pub = mergePub(pubs[match], in)
+			res.ID = pub.ID
+			if reflect.DeepEqual(pub, pubs[match]) {
+				res.Action = ImportUnchanged
+			} else {
+				res.Action = ImportUpdate
+			}
+		case in.ID != 0 && usedIDs[in.ID]:
+			res.Action = ImportSkip
+			res.ID = in.ID
+			res.Error = fmt.Sprintf("id %d is already used by another publication", in.ID)
+		default:
+			pub = in
+			if pub.ID == 0 {
+				pub.ID = (maxID/10 + 1) * 10
+			}
+			res.ID = pub.ID
+			res.Action = ImportCreate
+		}
+
+		if res.Action == ImportCreate || res.Action == ImportUpdate {
+			if err := pub.Validate(); err != nil {
+				res.Action = ImportSkip
+				res.Error = err.Error()
+			}
+		}
+
+		switch res.Action {
+		case ImportCreate:
+			pubs = append(pubs, pub)
+			match = len(pubs) - 1
+			index(match)
+			report.Created++
+		case ImportUpdate:
+			pubs[match] = pub
+			index(match)
+			report.Updated++
+		case ImportUnchanged:
+			report.Unchanged++
+		case ImportSkip:
+			report.Skipped++
+		}
+		if res.Action != ImportSkip {
+			touchedBy[match] = n
+		}
+		if res.Action == ImportCreate || res.Action == ImportUpdate {
+			saved := pub
+			res.Publication = &saved
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+func hasKey(m map[int]int, k int) bool {
+	_, ok := m[k]
+	return ok
+}
+
+// mergePub copies the non empty fields of in over existing, the id of
+// existing is kept.  A title that only differs in case or punctuation is
+// not worth an update, so the existing one is kept
+func mergePub(existing, in Publication) Publication {
+	pub := existing
+	if in.Title != "" && titleKey(in.Title) != titleKey(existing.Title) {
+		pub.Title = in.Title
+	}
+	if in.Cite != "" {
+		pub.Cite = in.Cite
+	}
+	if in.Link != "" {
+		pub.Link = in.Link
+	}
+	if in.Abstract != "" {
+		pub.Abstract = in.Abstract
+	}
+	if len(in.Slides) > 0 {
+		pub.Slides = in.Slides
+	}
+	if len(in.Authors) > 0 {
+		pub.Authors = in.Authors
+	}
+	if in.Year != 0 {
+		pub.Year = in.Year
+	}
+	if in.DOI != "" {
+		pub.DOI = in.DOI
+	}
+	return pub
+}
+
+// doiKey normalizes a DOI, they are not case sensitive and are often
+// written as a link
+func doiKey(doi string) string {
+	doi = strings.ToLower(strings.TrimSpace(doi))
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		doi = strings.TrimPrefix(doi, prefix)
+	}
+	return doi
+}
+
+// titleKey lower cases a title and drops everything but letters and
+// digits, so "On the Evaluation..." matches "On the evaluation"
+func titleKey(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
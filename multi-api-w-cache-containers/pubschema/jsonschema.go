@@ -0,0 +1,101 @@
+package pubschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaID is the $id of the generated JSON Schema, the major version is
+// part of it so that a breaking change gets a new id
+var SchemaID = fmt.Sprintf("https://architectingsoftware.com/schemas/publication/v%s.json",
+	strings.SplitN(Version, ".", 2)[0])
+
+// JSONSchema is the subset of JSON Schema that is needed to describe the
+// publication types
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Version              string                 `json:"version,omitempty"`
+	Type                 string                 `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+// PublicationSchema builds the JSON Schema for a Publication from the go
+// struct and its json tags, so it always matches what the services encode
+func PublicationSchema() *JSONSchema {
+	s := schemaFor(reflect.TypeOf(Publication{}))
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.ID = SchemaID
+	s.Title = "Publication"
+	s.Version = Version
+	return s
+}
+
+// PublicationSchemaJson returns the JSON Schema for a Publication as
+// indented JSON, this is what cmd/genschema writes out
+func PublicationSchemaJson() ([]byte, error) {
+	return json.MarshalIndent(PublicationSchema(), "", "  ")
+}
+
+func schemaFor(t reflect.Type) *JSONSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	panic(fmt.Sprintf("pubschema: no JSON Schema type for %s", t))
+}
+
+// structSchema follows the encoding/json rules, fields tagged with "-"
+// are skipped and fields without omitempty are required
+func structSchema(t reflect.Type) *JSONSchema {
+	s := &JSONSchema{
+		Type:       "object",
+		Properties: make(map[string]*JSONSchema),
+		Required:   []string{},
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+// Package pubschema holds the publication types that are shared by the
+// publications API, which stores them, and the reading list API, which
+// decodes them from the publications API.  Keeping one copy means the two
+// services can't drift apart.
+//
+// The schema is versioned.  Adding an optional field is fine, but renaming
+// or removing a field, or changing its type, breaks services that are
+// still running the old version.  Changes like that need a new major
+// version, see the compatibility test in the tests directory
+package pubschema
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Version is the version of the publication schema, it is also used in
+// the $id of the generated JSON Schema
+const Version = "1.1.0"
+
+type SlideLink struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+}
+type Publication struct {
+	ID       int         `json:"id"`
+	Title    string      `json:"title"`
+	Cite     string      `json:"cite"`
+	Link     string      `json:"link,omitempty"`
+	Slides   []SlideLink `json:"slides,omitempty"`
+	Abstract string      `json:"abstract"`
+
+	//Added in 1.1.0.  Cite is free text, these are used to export
+	//citations, see cite.go.  If they are missing they are guessed from Cite
+	Authors []string `json:"authors,omitempty"`
+	Year    int      `json:"year,omitempty"`
+	DOI     string   `json:"doi,omitempty"`
+}
+
+// Normalize trims stray whitespace from the fields that are validated,
+// some of the original sample data has links with leading spaces
+func (p *Publication) Normalize() {
+	p.Title = strings.TrimSpace(p.Title)
+	p.Link = strings.TrimSpace(p.Link)
+	for i := range p.Slides {
+		p.Slides[i].Link = strings.TrimSpace(p.Slides[i].Link)
+	}
+}
+
+// Validate checks a publication before it is written to the cache.  The
+// id must be positive, a title is required, and the paper link and any
+// slide links must be absolute http(s) URLs
+func (p *Publication) Validate() error {
+	if p.ID <= 0 {
+		return errors.New("id must be greater than 0")
+	}
+	if p.Title == "" {
+		return errors.New("title is required")
+	}
+	if p.Link != "" && !isValidURL(p.Link) {
+		return fmt.Errorf("link is not a valid URL: %s", p.Link)
+	}
+	for i, s := range p.Slides {
+		if !isValidURL(s.Link) {
+			return fmt.Errorf("slides[%d].link is not a valid URL: %s", i, s.Link)
+		}
+	}
+	return nil
+}
+
+func isValidURL(link string) bool {
+	u, err := url.ParseRequestURI(link)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/pubschema"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadSamplePubs(t *testing.T) map[int]pubschema.Publication {
+	b, err := os.ReadFile(SAMPLE_PUBS_FILE_NAME)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", SAMPLE_PUBS_FILE_NAME, err)
+	}
+	var pubs []pubschema.Publication
+	if err := json.Unmarshal(b, &pubs); err != nil {
+		t.Fatalf("could not parse %s: %v", SAMPLE_PUBS_FILE_NAME, err)
+	}
+	byID := make(map[int]pubschema.Publication)
+	for _, p := range pubs {
+		byID[p.ID] = p
+	}
+	return byID
+}
+
+func TestCiteAuthorsFromCite(t *testing.T) {
+	pubs := loadSamplePubs(t)
+
+	tests := map[int][]string{
+		10:  {"B. S. Mitchell", "S. Mancoridis"},
+		30:  {"B. S. Mitchell"},
+		40:  {"B. S. Mitchell", "S. Mancoridis", "M. Traverso"},
+		130: {"S. Mancoridis", "B.S.Mitchell", "Y.Chen", "E.R.Gansner"},
+		170: {"Brian S. Mitchell", "Ansh Chandnani", "John Carter", "Danai Roumelioti", "Spiros Mancoridis"},
+	}
+	for id, authors := range tests {
+		p := pubs[id]
+		assert.Equal(t, authors, p.CiteAuthors(), "publication %d", id)
+	}
+
+	p := pubs[10]
+	assert.Equal(t, 2008, p.CiteYear())
+	assert.Equal(t, "mitchell2008pub10", p.CiteKey())
+
+	//structured fields win over the guesses
+	p.Authors = []string{"Someone Else"}
+	p.Year = 2010
+	assert.Equal(t, []string{"Someone Else"}, p.CiteAuthors())
+	assert.Equal(t, "else2010pub10", p.CiteKey())
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		format, accept string
+		want           pubschema.CiteFormat
+	}{
+		{"", "", pubschema.FormatJSON},
+		{"", "text/html", pubschema.FormatJSON},
+		{"", "application/x-bibtex", pubschema.FormatBibTeX},
+		{"", "application/json;q=0.5, application/x-research-info-systems", pubschema.FormatRIS},
+		{"", "application/vnd.citationstyles.csl+json, */*;q=0.1", pubschema.FormatCSL},
+		{"bib", "application/json", pubschema.FormatBibTeX},
+		{"CSL-JSON", "", pubschema.FormatCSL},
+	}
+	for _, tc := range tests {
+		f, err := pubschema.NegotiateFormat(tc.format, tc.accept)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, f, "format=%q accept=%q", tc.format, tc.accept)
+	}
+
+	_, err := pubschema.NegotiateFormat("docx", "")
+	assert.Error(t, err)
+}
+
+func TestWriteCitations(t *testing.T) {
+	pubs := loadSamplePubs(t)
+	list := []pubschema.Publication{pubs[10], pubs[20]}
+	list[0].Title = "50% of a_b"
+
+	var bib bytes.Buffer
+	assert.NoError(t, pubschema.WriteCitations(&bib, pubschema.FormatBibTeX, list))
+	assert.Equal(t, 2, strings.Count(bib.String(), "@misc{"))
+	assert.Contains(t, bib.String(), "author = {B. S. Mitchell and S. Mancoridis}")
+	assert.Contains(t, bib.String(), `title = {{50\% of a\_b}}`)
+	assert.Contains(t, bib.String(), "url = {https://www.cs.drexel.edu/~bmitchell/pubs/JSC07.pdf}")
+
+	var ris bytes.Buffer
+	assert.NoError(t, pubschema.WriteCitations(&ris, pubschema.FormatRIS, list))
+	assert.Equal(t, 2, strings.Count(ris.String(), "ER  - "))
+	assert.Contains(t, ris.String(), "PY  - 2006\r\n")
+
+	var csl bytes.Buffer
+	assert.NoError(t, pubschema.WriteCitations(&csl, pubschema.FormatCSL, list))
+	var items []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(csl.Bytes(), &items))
+	assert.Len(t, items, 2)
+	assert.Equal(t, "mitchell2006pub20", items[1]["id"])
+}
@@ -0,0 +1,102 @@
+package tests
+
+//The reading list API decodes publications that were written by the
+//publications API.  If the two services are running different versions of
+//pubschema, a renamed, removed or retyped field means the reading list API
+//silently gets zero values, or fails to decode the publication at all.
+//These tests compare the current schema to a frozen baseline for the
+//current major version, only changes that old readers can't decode fail.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/pubschema"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	SCHEMA_FILE_NAME      = "../publication.schema.json"
+	BASELINE_FILE_PATTERN = "testdata/publication.v%s.schema.json"
+	SAMPLE_PUBS_FILE_NAME = "../../dbsetup/pubs.json"
+)
+
+func loadSchema(t *testing.T, fileName string) *pubschema.JSONSchema {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", fileName, err)
+	}
+	var s pubschema.JSONSchema
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("could not parse %s: %v", fileName, err)
+	}
+	return &s
+}
+
+// breakingChanges lists everything in old that a reader built from old
+// can no longer decode from new.  New optional fields are fine
+func breakingChanges(path string, old, new *pubschema.JSONSchema) []string {
+	if new == nil {
+		return []string{path + " was removed"}
+	}
+	if old.Type != new.Type {
+		return []string{fmt.Sprintf("%s changed type from %s to %s", path, old.Type, new.Type)}
+	}
+
+	var problems []string
+	if old.Items != nil {
+		problems = append(problems, breakingChanges(path+"[]", old.Items, new.Items)...)
+	}
+	if old.AdditionalProperties != nil {
+		problems = append(problems, breakingChanges(path+"{}", old.AdditionalProperties, new.AdditionalProperties)...)
+	}
+	for name, prop := range old.Properties {
+		problems = append(problems, breakingChanges(path+"."+name, prop, new.Properties[name])...)
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func TestSchemaIsBackwardCompatible(t *testing.T) {
+	major := strings.SplitN(pubschema.Version, ".", 2)[0]
+	baselineFile := fmt.Sprintf(BASELINE_FILE_PATTERN, major)
+	if _, err := os.Stat(baselineFile); err != nil {
+		t.Fatalf("no baseline for major version %s, copy publication.schema.json to tests/%s", major, baselineFile)
+	}
+
+	baseline := loadSchema(t, baselineFile)
+	current := pubschema.PublicationSchema()
+
+	problems := breakingChanges("publication", baseline, current)
+	assert.Empty(t, problems, "these changes break readers of schema v%s, bump the major version of pubschema", major)
+}
+
+func TestSchemaFileIsCurrent(t *testing.T) {
+	b, err := os.ReadFile(SCHEMA_FILE_NAME)
+	assert.NoError(t, err)
+
+	current, err := pubschema.PublicationSchemaJson()
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(current), string(b),
+		"publication.schema.json is out of date, run go run ./cmd/genschema > publication.schema.json")
+}
+
+func TestSampleDataDecodes(t *testing.T) {
+	f, err := os.Open(SAMPLE_PUBS_FILE_NAME)
+	if err != nil {
+		t.Fatalf("could not open %s: %v", SAMPLE_PUBS_FILE_NAME, err)
+	}
+	defer f.Close()
+
+	//unknown fields in the stored data mean a field was renamed
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	var pubs []pubschema.Publication
+	assert.NoError(t, dec.Decode(&pubs))
+	assert.NotEmpty(t, pubs)
+}
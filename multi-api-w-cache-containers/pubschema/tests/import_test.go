@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/pubschema"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleBibTeX = `
+@comment{exported by hand}
+@string{tse = "IEEE Transactions on Software Engineering"}
+
+@Article{mitchell06,
+  Author  = {Mitchell, Brian S. and Spiros Mancoridis},
+  Title   = "On the Automatic Modularization of {Software} Systems",
+  journal = tse,
+  volume  = 32,
+  number  = {3},
+  pages   = {193--208},
+  year    = 2006,
+  doi     = {10.1109/TSE.2006.31}
+}
+
+@inproceedings(clark03,
+  author = {J. Clark},
+  title = {Reformulating software engineering as a search problem \& more},
+  booktitle = {IEE Proceedings - Software},
+  year = {2003},
+)
+`
+
+func TestParseBibTeX(t *testing.T) {
+	pubs, err := pubschema.ParseBibTeX(strings.NewReader(sampleBibTeX))
+	assert.NoError(t, err)
+	assert.Len(t, pubs, 2)
+
+	assert.Equal(t, "On the Automatic Modularization of Software Systems", pubs[0].Title)
+	assert.Equal(t, []string{"Brian S. Mitchell", "Spiros Mancoridis"}, pubs[0].Authors)
+	assert.Equal(t, 2006, pubs[0].Year)
+	assert.Equal(t, "10.1109/TSE.2006.31", pubs[0].DOI)
+	assert.Equal(t, "Brian S. Mitchell, Spiros Mancoridis. In tse, Volume 32, Number 3, 2006, pp. 193-208.", pubs[0].Cite)
+
+	assert.Equal(t, "Reformulating software engineering as a search problem & more", pubs[1].Title)
+
+	_, err = pubschema.ParseBibTeX(strings.NewReader("@misc{x, title = {not closed}"))
+	assert.Error(t, err)
+}
+
+func TestBibTeXRoundTrip(t *testing.T) {
+	pubs := loadSamplePubs(t)
+	list := []pubschema.Publication{pubs[10], pubs[40], pubs[170]}
+	list[0].Title = "50% of a_b {braces}"
+
+	var bib bytes.Buffer
+	assert.NoError(t, pubschema.WriteCitations(&bib, pubschema.FormatBibTeX, list))
+
+	back, err := pubschema.ParseBibTeX(&bib)
+	assert.NoError(t, err)
+	assert.Len(t, back, len(list))
+	for i := range list {
+		list[i].Normalize()
+		assert.Equal(t, list[i].Title, back[i].Title)
+		assert.Equal(t, list[i].Cite, back[i].Cite)
+		assert.Equal(t, list[i].Link, back[i].Link)
+		assert.Equal(t, list[i].CiteAuthors(), back[i].Authors)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	csv := "Title,Authors,Year,DOI,URL\n" +
+		"\"A title, with a comma\",A. Author; B. Author,2020,10.1/abc,https://example.com/a.pdf\n"
+	pubs, err := pubschema.ParseCSV(strings.NewReader(csv))
+	assert.NoError(t, err)
+	assert.Len(t, pubs, 1)
+	assert.Equal(t, "A title, with a comma", pubs[0].Title)
+	assert.Equal(t, []string{"A. Author", "B. Author"}, pubs[0].Authors)
+	assert.Equal(t, "https://example.com/a.pdf", pubs[0].Link)
+
+	_, err = pubschema.ParseCSV(strings.NewReader("title,colour\nx,red\n"))
+	assert.Error(t, err)
+	_, err = pubschema.ParseCSV(strings.NewReader("title,year\nx,soon\n"))
+	assert.Error(t, err)
+}
+
+func TestPlanImport(t *testing.T) {
+	existing := []pubschema.Publication{
+		{ID: 10, Title: "First Paper", Cite: "A", DOI: "10.1/first", Link: " https://example.com/1.pdf"},
+		{ID: 20, Title: "Second Paper", Cite: "B"},
+	}
+	incoming := []pubschema.Publication{
+		{Title: "Renamed First Paper", DOI: "https://doi.org/10.1/FIRST"}, //update by doi
+		{Title: "second   PAPER!"},                                      //unchanged by title
+		{Title: "Third Paper", Cite: "C"},                                //create, gets id 30
+		{Title: "Third paper", Year: 2020},                               //duplicate of the one above
+		{ID: 20, Title: "Not The Second Paper"},                          //id already used
+		{Title: ""},                                                      //invalid
+	}
+
+	report := pubschema.PlanImport(existing, incoming)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 3, report.Skipped)
+
+	actions := []pubschema.ImportAction{}
+	for _, res := range report.Results {
+		actions = append(actions, res.Action)
+	}
+	assert.Equal(t, []pubschema.ImportAction{
+		pubschema.ImportUpdate, pubschema.ImportUnchanged, pubschema.ImportCreate,
+		pubschema.ImportSkip, pubschema.ImportSkip, pubschema.ImportSkip,
+	}, actions)
+
+	updated := report.Results[0]
+	assert.Equal(t, "doi", updated.MatchedBy)
+	assert.Equal(t, 10, updated.Publication.ID)
+	assert.Equal(t, "Renamed First Paper", updated.Publication.Title)
+	assert.Equal(t, "A", updated.Publication.Cite, "fields missing from the import are kept")
+
+	assert.Equal(t, "title", report.Results[1].MatchedBy)
+	assert.Equal(t, 30, report.Results[2].Publication.ID)
+	assert.Contains(t, report.Results[3].Error, "duplicate of entry 2")
+	assert.Contains(t, report.Results[4].Error, "already used")
+
+	//nothing that was passed in is changed
+	assert.Equal(t, " https://example.com/1.pdf", existing[0].Link)
+}
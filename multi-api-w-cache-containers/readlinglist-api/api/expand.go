@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/pubschema"
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultExpandWorkers is how many publications are fetched at the same
+// time when a reading list is expanded
+const DefaultExpandWorkers = 8
+
+// SetExpandWorkers changes how many publications are fetched at the same
+// time when a reading list is expanded
+func (r *ReadingListAPI) SetExpandWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	r.expandWorkers = workers
+}
+
+// expandReadingList fetches every publication in a reading list.  A fixed
+// pool of workers pulls items off of a channel so a long reading list can't
+// flood the publications API.  A publication that can not be loaded is
+// reported on its own item instead of failing the whole reading list
+func (r *ReadingListAPI) expandReadingList(ctx context.Context, rl schema.ReadingList) schema.ExpandedReadingList {
+	rl.NormalizeOrder()
+
+	items := make([]schema.ExpandedItem, len(rl.Order))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < r.expandWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			//each worker writes to its own index so no locking is needed
+			for idx := range jobs {
+				item := &items[idx]
+				pub, err := r.getPublication(ctx, item.Location)
+				if err != nil {
+					item.Status = pubErrorStatus(err)
+					item.Error = err.Error()
+					if item.Status >= http.StatusInternalServerError {
+						//like a 5xx problem, the caller doesn't see the cause
+						slog.ErrorContext(ctx, "Error expanding reading list item", "location", item.Location, "error", err)
+						item.Error = httpkit.InternalErrorDetail
+					}
+					continue
+				}
+				item.Status = http.StatusOK
+				item.Publication = &pub
+			}
+		}()
+	}
+
+	for idx, key := range rl.Order {
+		items[idx] = schema.ExpandedItem{Key: key, Location: rl.Items[key], Progress: rl.ItemProgress(key)}
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return schema.ExpandedReadingList{
+		ReadingList:  rl,
+		Publications: items,
+	}
+}
+
+// exportReadingList sends every publication in the reading list as one
+// bibliography, in reading order.  Publications that could not be loaded
+// are left out and their item keys are listed in the X-Missing-Items header
+func (r *ReadingListAPI) exportReadingList(c *gin.Context, rlId string, rl schema.ReadingList, format pubschema.CiteFormat) {
+	expanded := r.expandReadingList(c.Request.Context(), rl)
+
+	pubs := []schema.Publication{}
+	missing := []string{}
+	for _, item := range expanded.Publications {
+		if item.Publication == nil {
+			missing = append(missing, item.Key)
+			continue
+		}
+		pubs = append(pubs, *item.Publication)
+	}
+
+	if len(missing) > 0 {
+		c.Header("X-Missing-Items", strings.Join(missing, ","))
+	}
+	httpkit.ServeDownload(c, format.ContentType(), "publist-"+rlId+format.FileExt(), func(w io.Writer) error {
+		return pubschema.WriteCitations(w, format, pubs)
+	})
+}
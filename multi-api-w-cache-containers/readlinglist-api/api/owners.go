@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultUserHeader is the header that says who is making a request.  The
+// reading list API does not check passwords or tokens itself, it trusts a
+// gateway in front of it to authenticate the caller and set this header.
+// Anyone can set a header though, so it is only believed when the gateway
+// token is sent with it, see CheckUser
+const DefaultUserHeader = "X-User"
+
+// GatewayTokenHeader is where the gateway sends the gateway token, on
+// every request that it sets the user header on
+const GatewayTokenHeader = "X-Gateway-Token"
+
+type access int
+
+const (
+	readAccess access = iota
+	changeAccess
+)
+
+// SetUserHeader changes the header that the caller's user name is read from
+func (r *ReadingListAPI) SetUserHeader(header string) {
+	r.userHeader = header
+}
+
+// SetGatewayToken sets the secret that only the gateway knows.  Without a
+// token the user header is never trusted
+func (r *ReadingListAPI) SetGatewayToken(token string) {
+	r.gatewayToken = token
+}
+
+// fromGateway reports if the request has the gateway token
+func (r *ReadingListAPI) fromGateway(c *gin.Context) bool {
+	token := c.GetHeader(GatewayTokenHeader)
+	return r.gatewayToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(r.gatewayToken)) == 1
+}
+
+// CheckUser returns gin middleware that turns away requests that have the
+// user header but not the gateway token with a 401.  They did not come
+// through the gateway, so the user was made up by the caller.  Requests
+// without the user header are let through, they can use shared lists
+func (r *ReadingListAPI) CheckUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(r.userHeader) != "" && !r.fromGateway(c) {
+			httpkit.AbortWithStatus(c, http.StatusUnauthorized, "The "+r.userHeader+" header is only accepted from the gateway")
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUser returns who is making the request, or "" if we don't know.
+// The user header is ignored without the gateway token, even if CheckUser
+// was left off a route
+func (r *ReadingListAPI) currentUser(c *gin.Context) string {
+	if !r.fromGateway(c) {
+		return ""
+	}
+	return strings.TrimSpace(c.GetHeader(r.userHeader))
+}
+
+// checkAccess makes sure the caller can use the reading list.  Private
+// lists of other users are reported as not found so they can't be
+// discovered by guessing ids.  If the caller can't use the list an error
+// is sent and false is returned
+func (r *ReadingListAPI) checkAccess(c *gin.Context, cacheKey string, rl *schema.ReadingList, mode access) bool {
+	user := r.currentUser(c)
+	if !rl.CanRead(user) {
+		httpkit.AbortWithError(c, listNotFound(cacheKey))
+		return false
+	}
+	if mode == changeAccess && !rl.CanChange(user) {
+		if user == "" {
+			httpkit.AbortWithStatus(c, http.StatusUnauthorized, "Reading list "+cacheKey+" belongs to a user, provide the "+r.userHeader+" header")
+		} else {
+			httpkit.AbortWithStatus(c, http.StatusForbidden, "Reading list "+cacheKey+" belongs to "+rl.Owner)
+		}
+		return false
+	}
+	return true
+}
+
+// implementation for PUT /publists/:id/:idx/progress
+// sets the reading state of an item and the notes about it, the body looks
+// like {"state": "in-progress", "notes": "read up to section 3"}.  The
+// state is one of unread, in-progress or read
+func (r *ReadingListAPI) UpdateItemProgress(c *gin.Context) {
+	cacheKey, rl, ok := r.readingListFromParam(c, changeAccess)
+	if !ok {
+		return
+	}
+
+	rlIdxKey := c.Param("idx")
+	if _, exists := rl.Items[rlIdxKey]; !exists {
+		httpkit.AbortWithStatus(c, http.StatusNotFound, "Could not find publication in reading list with id="+rlIdxKey)
+		return
+	}
+
+	var progress schema.ItemProgress
+	if err := c.ShouldBindJSON(&progress); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Invalid progress: "+err.Error())
+		return
+	}
+	if !schema.IsValidState(progress.State) {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "State must be unread, in-progress or read")
+		return
+	}
+
+	now := time.Now().UTC()
+	progress.UpdatedAt = &now
+	if rl.Progress == nil {
+		rl.Progress = make(map[string]schema.ItemProgress)
+	}
+	rl.Progress[rlIdxKey] = progress
+	rl.NormalizeOrder()
+
+	if !r.saveReadingList(c, cacheKey, &rl) {
+		return
+	}
+	c.JSON(http.StatusOK, rl)
+}
@@ -2,16 +2,28 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-
+	"strconv"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/pubschema"
+	"architectingsoftware.com/reading-list-api/pubcache"
+	"architectingsoftware.com/reading-list-api/pubclient"
 	"architectingsoftware.com/reading-list-api/schema"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
+)
 
-	"github.com/go-resty/resty/v2"
+const (
+	RedisKeyPrefix = "publist:"
 )
 
 type cache struct {
@@ -23,17 +35,28 @@ type cache struct {
 type ReadingListAPI struct {
 	cache
 	pubAPIURL string
-	apiClient *resty.Client
+	apiClient *pubclient.Client
+	pubCache  *pubcache.PubCache //nil when publications are not cached
+
+	expandWorkers  int
+	redirectPolicy RedirectPolicy
+	userHeader     string
+	gatewayToken   string //the user header is only trusted with it, "" trusts nobody
+	cacheToken     string //needed for DELETE /cache/pubs/:id, "" turns it off
 }
 
-func NewReadingListAPI(location string, pubAPIurl string) (*ReadingListAPI, error) {
+func NewReadingListAPI(redisOpts *redis.Options, pubAPIurl string, clientCfg pubclient.Config) (*ReadingListAPI, error) {
 
-	apiClient := resty.New()
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+	//The client used to call the publications API has timeouts, retries
+	//and a circuit breaker, see the pubclient package
+	apiClient, err := pubclient.New(pubAPIurl, clientCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	//Connect to redis.  The options come from the cache settings, see
+	//config.Cache in httpkit
+	client := redis.NewClient(redisOpts)
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -41,9 +64,9 @@ func NewReadingListAPI(location string, pubAPIurl string) (*ReadingListAPI, erro
 
 	//This is the reccomended way to ensure that our redis connection
 	//is working
-	err := client.Ping(ctx).Err()
+	err = client.Ping(ctx).Err()
 	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
+		slog.Error("Error connecting to redis", "error", err)
 		return nil, err
 	}
 
@@ -63,70 +86,179 @@ func NewReadingListAPI(location string, pubAPIurl string) (*ReadingListAPI, erro
 			helper:  jsonHelper,
 			context: ctx,
 		},
-		pubAPIURL: pubAPIurl,
-		apiClient: apiClient,
+		pubAPIURL:      pubAPIurl,
+		apiClient:      apiClient,
+		expandWorkers:  DefaultExpandWorkers,
+		redirectPolicy: DefaultRedirectPolicy(),
+		userHeader:     DefaultUserHeader,
 	}, nil
 }
 
+// Close closes the connection to redis, which the redis publication cache
+// shares, and the idle connections to the publications API.  It is called
+// when the API shuts down
+func (r *ReadingListAPI) Close() error {
+	r.apiClient.Close()
+	return r.client.Close()
+}
+
+// EnablePubCache turns on the read-through cache of publications.  The
+// mode is either "memory" for an in process LRU cache holding up to size
+// publications, or "redis" to share the cache between replicas using the
+// same redis instance as the reading lists
+func (r *ReadingListAPI) EnablePubCache(mode string, ttl, staleFor time.Duration, size int) error {
+	var store pubcache.Store
+	switch mode {
+	case "memory":
+		store = pubcache.NewMemoryStore(size)
+	case "redis":
+		store = pubcache.NewRedisStore(r.context, r.client, ttl+staleFor)
+	default:
+		return fmt.Errorf("unknown publication cache mode %q, use memory or redis", mode)
+	}
+
+	r.pubCache = pubcache.New(store, r.apiClient, ttl, staleFor)
+	return nil
+}
+
+// getPublication gets a publication from the publications API, going
+// through the cache if it is enabled
+func (r *ReadingListAPI) getPublication(ctx context.Context, location string) (schema.Publication, error) {
+	if r.pubCache != nil {
+		return r.pubCache.GetPublication(ctx, location)
+	}
+
+	resp, err := r.apiClient.Get(ctx, location, nil)
+	if err != nil {
+		return schema.Publication{}, err
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return schema.Publication{}, pubcache.ErrPubNotFound
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return schema.Publication{}, pubclient.NewStatusError(resp)
+	}
+
+	var pub schema.Publication
+	if err := json.Unmarshal(resp.Body(), &pub); err != nil {
+		return schema.Publication{}, err
+	}
+	return pub, nil
+}
+
+// pubErrorStatus picks the status to send to our clients when getting a
+// publication from the publications API fails.  A missing publication is
+// a 404, a location that is not a publication is a 400, an open circuit
+// breaker means the publications API is known to be down so it is a 503, a
+// timeout is a 504 and anything else is a 502.  Only a 404 and a 400 say
+// what went wrong, the others are logged
+func pubErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, pubcache.ErrPubNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pubclient.ErrBadLocation):
+		return http.StatusBadRequest
+	case errors.Is(err, pubclient.ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	case pubclient.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// SetCacheToken sets the bearer token that DELETE /cache/pubs/:id needs,
+// the publications API is given the same token.  Without a token the
+// route is turned off
+func (r *ReadingListAPI) SetCacheToken(token string) {
+	r.cacheToken = token
+}
+
+// implementation for DELETE /cache/pubs/:id
+// this is the invalidation hook for the publication cache, the
+// publications API calls it when a publication changes so reading lists
+// do not show the old version.  It needs the cache token so nobody else
+// can empty the cache
+func (r *ReadingListAPI) InvalidatePublication(c *gin.Context) {
+	if r.cacheToken == "" {
+		httpkit.AbortWithStatus(c, http.StatusForbidden, "Cache invalidation is turned off, start the API with a cache token to turn it on")
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(r.cacheToken)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="cache"`)
+		httpkit.AbortWithStatus(c, http.StatusUnauthorized, "Dropping publications from the cache needs the cache token as a bearer token")
+		return
+	}
+
+	if r.pubCache != nil {
+		r.pubCache.Invalidate(c.Param("id"))
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (r *ReadingListAPI) GetReadingList(c *gin.Context) {
 
 	rlId := c.Param("id")
 	if rlId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "No publication ID provided")
 		return
 	}
 
-	cacheKey := "publist:" + rlId
-	rlBytes, err := r.helper.JSONGet(cacheKey, ".")
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find reading list in cache with id=" + cacheKey})
+	_, rl, ok := r.readingListFromParam(c, readAccess)
+	if !ok {
 		return
 	}
 
-	var rl schema.ReadingList
-	err = json.Unmarshal(rlBytes.([]byte), &rl)
+	format, err := pubschema.NegotiateFormat(c.Query("format"), c.GetHeader("Accept"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cached data seems to be wrong type"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.Header("Vary", "Accept")
+	if format != pubschema.FormatJSON {
+		r.exportReadingList(c, rlId, rl, format)
+		return
+	}
+
+	//GET /publists/:id?expand=pubs includes every publication in the
+	//response so clients do not have to fetch them one at a time
+	if c.Query("expand") == "pubs" {
+		c.JSON(http.StatusOK, r.expandReadingList(c.Request.Context(), rl))
 		return
 	}
 
+	rl.NormalizeOrder()
 	c.JSON(http.StatusOK, rl)
 }
 
 func (r *ReadingListAPI) GetPubFromReadingList(c *gin.Context) {
 	rlId := c.Param("id")
 	if rlId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "No publication ID provided")
 		return
 	}
 
 	rlIdxKey := c.Param("idx")
 	if rlIdxKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication incdex provided"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "No publication incdex provided")
 		return
 	}
 
-	cacheKey := "publist:" + rlId
-	var rl schema.ReadingList
-	err := r.getItemFromRedis(cacheKey, &rl)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find reading list in cache with id=" + cacheKey})
+	_, rl, ok := r.readingListFromParam(c, readAccess)
+	if !ok {
 		return
 	}
 
 	pubItemLocation, ok := rl.Items[rlIdxKey]
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find publication in reading list with id=" + rlIdxKey})
+		httpkit.AbortWithStatus(c, http.StatusNotFound, "Could not find publication in reading list with id="+rlIdxKey)
 		return
 	}
 
-	pubURL := r.pubAPIURL + pubItemLocation
-	var pub schema.Publication
-
-	_, err = r.apiClient.R().SetResult(&pub).Get(pubURL)
+	pub, err := r.getPublication(c.Request.Context(), pubItemLocation)
 	if err != nil {
-		emsg := "Could not get publication from API: (" + pubURL + ")" + err.Error()
-		c.JSON(http.StatusNotFound, gin.H{"error": emsg})
+		httpkit.AbortWithErrorStatus(c, pubErrorStatus(err), err)
 		return
 	}
 
@@ -136,74 +268,109 @@ func (r *ReadingListAPI) GetPubFromReadingList(c *gin.Context) {
 func (r *ReadingListAPI) RedirectWithPublication(c *gin.Context) {
 	rlId := c.Param("id")
 	if rlId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "No publication ID provided")
 		return
 	}
 
 	rlIdxKey := c.Param("idx")
 	if rlIdxKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication incdex provided"})
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "No publication incdex provided")
 		return
 	}
 
-	cacheKey := "publist:" + rlId
-	var rl schema.ReadingList
-	err := r.getItemFromRedis(cacheKey, &rl)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find reading list in cache with id=" + cacheKey})
+	_, rl, ok := r.readingListFromParam(c, readAccess)
+	if !ok {
 		return
 	}
 
 	pubItemLocation, ok := rl.Items[rlIdxKey]
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find publication in reading list with id=" + rlIdxKey})
+		httpkit.AbortWithStatus(c, http.StatusNotFound, "Could not find publication in reading list with id="+rlIdxKey)
 		return
 	}
 
-	pubURL := r.pubAPIURL + pubItemLocation
-	var pub schema.Publication
-
-	_, err = r.apiClient.R().SetResult(&pub).Get(pubURL)
+	pub, err := r.getPublication(c.Request.Context(), pubItemLocation)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not get publication from API"})
+		httpkit.AbortWithErrorStatus(c, pubErrorStatus(err), err)
 		return
 	}
 
 	if pub.Link == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Publication does not have a link"})
+		httpkit.AbortWithStatus(c, http.StatusNotFound, "Publication does not have a link")
 		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, pub.Link)
+	//only redirect to hosts we trust, see RedirectPolicy
+	link, err := r.redirectPolicy.CheckLink(pub.Link)
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusForbidden, "Will not redirect to publication link: "+pub.Link)
+		return
+	}
+
+	r.recordClick(c.Request.Context(), rlId, rlIdxKey, pubItemLocation)
+	c.Redirect(r.redirectPolicy.Status, link)
 }
 
+// implementation for GET /publists
+// returns every reading list the caller can see.  ?owner=me only returns
+// the caller's own lists, and ?owner=<user> the lists of that user
 func (r *ReadingListAPI) GetReadingLists(c *gin.Context) {
 
-	var readList []schema.ReadingList
-	var readItem schema.ReadingList
+	user := r.currentUser(c)
+	owner := c.Query("owner")
+	if owner == "me" {
+		if user == "" {
+			httpkit.AbortWithStatus(c, http.StatusUnauthorized, "owner=me needs the "+r.userHeader+" header")
+			return
+		}
+		owner = user
+	}
+
+	readList := []schema.ReadingList{}
 
 	//Lets query redis for all of the items
-	pattern := "publist:*"
+	pattern := RedisKeyPrefix + "*"
 	ks, _ := r.client.Keys(r.context, pattern).Result()
 	for _, key := range ks {
+		//a new struct each time, unmarshalling into the same one would
+		//merge the items maps of the lists
+		var readItem schema.ReadingList
 		err := r.getItemFromRedis(key, &readItem)
+		if errors.Is(err, httpkit.ErrNotFound) {
+			//deleted since we got the keys
+			continue
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find reading list in cache with id=" + key})
+			httpkit.AbortWithError(c, err)
 			return
 		}
+		if !readItem.CanRead(user) || (owner != "" && readItem.Owner != owner) {
+			continue
+		}
+		readItem.NormalizeOrder()
 		readList = append(readList, readItem)
 	}
 
 	c.JSON(http.StatusOK, readList)
 }
 
-// Helper to return a ToDoItem from redis provided a key
+// listNotFound is the error for a reading list that does not exist, or
+// that the caller is not allowed to see
+func listNotFound(key string) error {
+	return fmt.Errorf("reading list %s: %w", key, httpkit.ErrNotFound)
+}
+
+// Helper to return a reading list from redis provided a key, a missing key
+// is httpkit.ErrNotFound
 func (r *ReadingListAPI) getItemFromRedis(key string, rl *schema.ReadingList) error {
 
 	//Lets query redis for the item, note we can return parts of the
 	//json structure, the second parameter "." means return the entire
 	//json structure
 	itemObject, err := r.helper.JSONGet(key, ".")
+	if errors.Is(err, redis.Nil) {
+		return listNotFound(key)
+	}
 	if err != nil {
 		return err
 	}
@@ -219,3 +386,292 @@ func (r *ReadingListAPI) getItemFromRedis(key string, rl *schema.ReadingList) er
 
 	return nil
 }
+
+// implementation for POST /publists
+// creates a new reading list, any items provided must reference
+// publications that exist in the publications API
+func (r *ReadingListAPI) AddReadingList(c *gin.Context) {
+	var rl schema.ReadingList
+	if err := c.ShouldBindJSON(&rl); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Invalid reading list: "+err.Error())
+		return
+	}
+
+	if rl.ID <= 0 {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Reading list id must be greater than 0")
+		return
+	}
+	if strings.TrimSpace(rl.Description) == "" {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Reading list description is required")
+		return
+	}
+
+	cacheKey := RedisKeyPrefix + strconv.Itoa(rl.ID)
+	var existing schema.ReadingList
+	err := r.getItemFromRedis(cacheKey, &existing)
+	if err == nil {
+		httpkit.AbortWithError(c, fmt.Errorf("reading list %s: %w", cacheKey, httpkit.ErrConflict))
+		return
+	}
+	if !errors.Is(err, httpkit.ErrNotFound) {
+		httpkit.AbortWithError(c, err)
+		return
+	}
+
+	//the list belongs to whoever created it, new lists are private unless
+	//they are asked to be shared.  Lists created without a user are shared
+	//since nobody would be able to see a private one
+	rl.Owner = r.currentUser(c)
+	if rl.Visibility == "" {
+		rl.Visibility = schema.VisibilityPrivate
+		if rl.Owner == "" {
+			rl.Visibility = schema.VisibilityShared
+		}
+	}
+	if !schema.IsValidVisibility(rl.Visibility) {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Visibility must be private or shared")
+		return
+	}
+	if rl.Visibility == schema.VisibilityPrivate && rl.Owner == "" {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Private reading lists need an owner, provide the "+r.userHeader+" header")
+		return
+	}
+	rl.Progress = nil
+
+	for key, location := range rl.Items {
+		if !r.checkPublication(c, key, location) {
+			return
+		}
+	}
+	if rl.Items == nil {
+		rl.Items = make(map[string]string)
+	}
+	rl.NormalizeOrder()
+
+	if !r.saveReadingList(c, cacheKey, &rl) {
+		return
+	}
+	c.JSON(http.StatusCreated, rl)
+}
+
+// implementation for PATCH /publists/:id
+// renames a reading list or changes who can see it, the body looks like
+// {"description": "new name", "visibility": "shared"} and either field
+// can be left out
+func (r *ReadingListAPI) UpdateReadingList(c *gin.Context) {
+	cacheKey, rl, ok := r.readingListFromParam(c, changeAccess)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || (body.Description == nil && body.Visibility == nil) {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Provide a description or a visibility")
+		return
+	}
+
+	if body.Description != nil {
+		if strings.TrimSpace(*body.Description) == "" {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "Reading list description is required")
+			return
+		}
+		rl.Description = *body.Description
+	}
+	if body.Visibility != nil {
+		if !schema.IsValidVisibility(*body.Visibility) {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "Visibility must be private or shared")
+			return
+		}
+		if *body.Visibility == schema.VisibilityPrivate && rl.Owner == "" {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "Reading lists without an owner can't be private")
+			return
+		}
+		rl.Visibility = *body.Visibility
+	}
+
+	if !r.saveReadingList(c, cacheKey, &rl) {
+		return
+	}
+	c.JSON(http.StatusOK, rl)
+}
+
+// implementation for DELETE /publists/:id
+func (r *ReadingListAPI) DeleteReadingList(c *gin.Context) {
+	cacheKey, _, ok := r.readingListFromParam(c, changeAccess)
+	if !ok {
+		return
+	}
+
+	numDeleted, err := r.client.Del(r.context, cacheKey).Result()
+	if err != nil {
+		httpkit.AbortWithError(c, fmt.Errorf("could not delete reading list: %w", err))
+		return
+	}
+	if numDeleted == 0 {
+		httpkit.AbortWithError(c, listNotFound(cacheKey))
+		return
+	}
+
+	//the click counts for the publications stay, they are not per list
+	r.client.Del(r.context, RedisStatsKeyPrefix+c.Param("id"))
+
+	c.Status(http.StatusNoContent)
+}
+
+// implementation for POST /publists/:id/items
+// adds a publication to the end of a reading list, the body looks like
+// {"key": "JSC07", "pubId": 10}
+func (r *ReadingListAPI) AddReadingListItem(c *gin.Context) {
+	cacheKey, rl, ok := r.readingListFromParam(c, changeAccess)
+	if !ok {
+		return
+	}
+
+	var item schema.ReadingListItem
+	if err := c.ShouldBindJSON(&item); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Invalid reading list item: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(item.Key) == "" || item.PubID <= 0 {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Reading list items need a key and a pubId greater than 0")
+		return
+	}
+	if _, exists := rl.Items[item.Key]; exists {
+		httpkit.AbortWithStatus(c, http.StatusConflict, "Reading list already has an item with key="+item.Key)
+		return
+	}
+
+	location := fmt.Sprintf("/pubs/%d", item.PubID)
+	if !r.checkPublication(c, item.Key, location) {
+		return
+	}
+
+	if rl.Items == nil {
+		rl.Items = make(map[string]string)
+	}
+	rl.NormalizeOrder()
+	rl.Items[item.Key] = location
+	rl.Order = append(rl.Order, item.Key)
+
+	if !r.saveReadingList(c, cacheKey, &rl) {
+		return
+	}
+	c.JSON(http.StatusCreated, rl)
+}
+
+// implementation for DELETE /publists/:id/:idx
+// removes a publication from a reading list
+func (r *ReadingListAPI) DeleteReadingListItem(c *gin.Context) {
+	cacheKey, rl, ok := r.readingListFromParam(c, changeAccess)
+	if !ok {
+		return
+	}
+
+	rlIdxKey := c.Param("idx")
+	if _, exists := rl.Items[rlIdxKey]; !exists {
+		httpkit.AbortWithStatus(c, http.StatusNotFound, "Could not find publication in reading list with id="+rlIdxKey)
+		return
+	}
+
+	delete(rl.Items, rlIdxKey)
+	delete(rl.Progress, rlIdxKey)
+	rl.NormalizeOrder()
+
+	if !r.saveReadingList(c, cacheKey, &rl) {
+		return
+	}
+	//a new item added later with the same key starts with no clicks
+	r.client.HDel(r.context, RedisStatsKeyPrefix+c.Param("id"), rlIdxKey)
+	c.JSON(http.StatusOK, rl)
+}
+
+// implementation for PUT /publists/:id/order
+// reorders a reading list, the body is a JSON array with every item key
+// in the new order, for example ["TSE", "JSC07"]
+func (r *ReadingListAPI) ReorderReadingList(c *gin.Context) {
+	cacheKey, rl, ok := r.readingListFromParam(c, changeAccess)
+	if !ok {
+		return
+	}
+
+	var order []string
+	if err := c.ShouldBindJSON(&order); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Order must be a JSON array of item keys")
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range order {
+		if _, exists := rl.Items[key]; !exists || seen[key] {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "Unknown or repeated item key in order: "+key)
+			return
+		}
+		seen[key] = true
+	}
+	if len(order) != len(rl.Items) {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Order must include every item in the reading list")
+		return
+	}
+
+	rl.Order = order
+	if !r.saveReadingList(c, cacheKey, &rl) {
+		return
+	}
+	c.JSON(http.StatusOK, rl)
+}
+
+// readingListFromParam loads the reading list for the :id path parameter
+// and checks that the caller can use it, see checkAccess.  If it can not
+// be found or used an error is sent and ok is false
+func (r *ReadingListAPI) readingListFromParam(c *gin.Context, mode access) (string, schema.ReadingList, bool) {
+	cacheKey := RedisKeyPrefix + c.Param("id")
+	var rl schema.ReadingList
+	if err := r.getItemFromRedis(cacheKey, &rl); err != nil {
+		httpkit.AbortWithError(c, err)
+		return "", rl, false
+	}
+	if !r.checkAccess(c, cacheKey, &rl, mode) {
+		return "", rl, false
+	}
+	return cacheKey, rl, true
+}
+
+func (r *ReadingListAPI) saveReadingList(c *gin.Context, cacheKey string, rl *schema.ReadingList) bool {
+	if _, err := r.helper.JSONSet(cacheKey, ".", rl); err != nil {
+		httpkit.AbortWithError(c, fmt.Errorf("could not save reading list: %w", err))
+		return false
+	}
+	return true
+}
+
+// checkPublication makes sure a publication exists in the publications API
+// before it is added to a reading list.  If the location is not a
+// publication, the publication does not exist, or the API could not be
+// reached, an error is sent and false is returned
+func (r *ReadingListAPI) checkPublication(c *gin.Context, key, location string) bool {
+	//the location is sent to the publications API, so only paths of
+	//publications are let through
+	if err := pubclient.CheckLocation(location); err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "Item "+key+": "+err.Error())
+		return false
+	}
+
+	resp, err := r.apiClient.Get(c.Request.Context(), location, nil)
+	if err != nil {
+		httpkit.AbortWithErrorStatus(c, pubErrorStatus(err), fmt.Errorf("checking %s: %w", r.apiClient.URL(location), err))
+		return false
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return true
+	case http.StatusNotFound:
+		httpkit.AbortWithStatus(c, http.StatusUnprocessableEntity, "Publication for item "+key+" does not exist: "+location)
+	default:
+		httpkit.AbortWithStatus(c, http.StatusBadGateway, "Publication API returned "+resp.Status()+" for "+location)
+	}
+	return false
+}
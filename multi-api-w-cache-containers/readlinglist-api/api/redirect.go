@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/reading-list-api/pubcache"
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	//clicks for each item in a reading list are kept in a redis hash
+	//keyed by reading list id, the field is the item key.  This must not
+	//start with RedisKeyPrefix or GetReadingLists would pick it up
+	RedisStatsKeyPrefix = "publiststats:"
+	//clicks for each publication across every reading list, the field is
+	//the publication id
+	RedisPubClicksKey = "pubclicks"
+)
+
+// ErrLinkNotAllowed is returned by RedirectPolicy.CheckLink when a
+// publication link points somewhere we will not redirect to
+var ErrLinkNotAllowed = errors.New("publication link is not allowed")
+
+// RedirectPolicy controls where /publists/:id/:idx/paper will send a
+// browser.  Without it, anyone that can write a publication could use the
+// reading list API as an open redirect
+type RedirectPolicy struct {
+	Schemes []string
+	//Hosts can be an exact host name, or "*.example.com" to allow any
+	//sub domain of example.com
+	Hosts []string
+	//Status is the redirect status code, 302 or 307.  A 301 is never used
+	//since browsers cache it forever, even after the link changes
+	Status int
+}
+
+func DefaultRedirectPolicy() RedirectPolicy {
+	return RedirectPolicy{
+		Schemes: []string{"https", "http"},
+		Hosts:   []string{"www.cs.drexel.edu"},
+		Status:  http.StatusFound,
+	}
+}
+
+// Validate checks the policy when the service starts
+func (rp RedirectPolicy) Validate() error {
+	if rp.Status != http.StatusFound && rp.Status != http.StatusTemporaryRedirect {
+		return fmt.Errorf("redirect status must be 302 or 307, not %d", rp.Status)
+	}
+	if len(rp.Schemes) == 0 || len(rp.Hosts) == 0 {
+		return errors.New("redirects need at least one allowed scheme and host")
+	}
+	return nil
+}
+
+// CheckLink returns the cleaned up link if the policy allows redirecting
+// to it, otherwise it returns ErrLinkNotAllowed
+func (rp RedirectPolicy) CheckLink(link string) (string, error) {
+	link = strings.TrimSpace(link)
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" || u.User != nil {
+		return "", ErrLinkNotAllowed
+	}
+	if !containsFold(rp.Schemes, u.Scheme) {
+		return "", ErrLinkNotAllowed
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range rp.Hosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return link, nil
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return link, nil
+		}
+	}
+	return "", ErrLinkNotAllowed
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRedirectPolicy changes where the paper redirect is allowed to go
+func (r *ReadingListAPI) SetRedirectPolicy(rp RedirectPolicy) error {
+	if err := rp.Validate(); err != nil {
+		return err
+	}
+	r.redirectPolicy = rp
+	return nil
+}
+
+// recordClick counts a redirect for the reading list item and for the
+// publication.  Stats are not worth failing the redirect over so errors
+// are only logged
+func (r *ReadingListAPI) recordClick(ctx context.Context, rlId, itemKey, location string) {
+	pipe := r.client.TxPipeline()
+	pipe.HIncrBy(r.context, RedisStatsKeyPrefix+rlId, itemKey, 1)
+	pipe.HIncrBy(r.context, RedisPubClicksKey, pubcache.PubIDFromLocation(location), 1)
+	if _, err := pipe.Exec(r.context); err != nil {
+		slog.WarnContext(ctx, "Could not record click", "readingList", rlId, "item", itemKey, "error", err)
+	}
+}
+
+// implementation for GET /publists/:id/stats
+// returns how many times the paper for each item in the reading list was
+// opened, from this reading list and from every reading list
+func (r *ReadingListAPI) GetReadingListStats(c *gin.Context) {
+	_, rl, ok := r.readingListFromParam(c, readAccess)
+	if !ok {
+		return
+	}
+	rl.NormalizeOrder()
+
+	rlClicks, err := r.client.HGetAll(r.context, RedisStatsKeyPrefix+c.Param("id")).Result()
+	if err != nil {
+		httpkit.AbortWithError(c, fmt.Errorf("could not get reading list stats: %w", err))
+		return
+	}
+
+	pubIds := make([]string, 0, len(rl.Order))
+	for _, key := range rl.Order {
+		pubIds = append(pubIds, pubcache.PubIDFromLocation(rl.Items[key]))
+	}
+	var pubClicks []interface{}
+	if len(pubIds) > 0 {
+		pubClicks, err = r.client.HMGet(r.context, RedisPubClicksKey, pubIds...).Result()
+		if err != nil {
+			httpkit.AbortWithError(c, fmt.Errorf("could not get publication stats: %w", err))
+			return
+		}
+	}
+
+	stats := schema.ReadingListStats{
+		ID:    rl.ID,
+		Items: make(map[string]schema.ItemStats),
+	}
+	for i, key := range rl.Order {
+		item := schema.ItemStats{Location: rl.Items[key]}
+		fmt.Sscan(rlClicks[key], &item.Clicks)
+		if s, ok := pubClicks[i].(string); ok {
+			fmt.Sscan(s, &item.PubClicks)
+		}
+		stats.Total += item.Clicks
+		stats.Items[key] = item
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag architectingsoftware/cnse-publist-api:v1  -f ./dockerfile .
\ No newline at end of file
+docker build --tag architectingsoftware/cnse-publist-api:v1  -f ./dockerfile --build-context httpkit=../../httpkit ..
\ No newline at end of file
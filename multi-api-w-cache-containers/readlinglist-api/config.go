@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+
+	"architectingsoftware.com/httpkit/config"
+	"architectingsoftware.com/reading-list-api/api"
+	"architectingsoftware.com/reading-list-api/pubclient"
+)
+
+// Config is every setting of the reading list API, see the config package
+// in httpkit for how the defaults, a file, the environment and the flags
+// are layered
+type Config struct {
+	Server    config.Server    `yaml:"server" toml:"server"`
+	Cache     config.Cache     `yaml:"cache" toml:"cache"`
+	CORS      config.CORS      `yaml:"cors" toml:"cors"`
+	PubAPI    PubAPIConfig     `yaml:"pubAPI" toml:"pubAPI"`
+	PubCache  PubCacheConfig   `yaml:"pubCache" toml:"pubCache"`
+	Lists     ListsConfig      `yaml:"lists" toml:"lists"`
+	Redirect  RedirectConfig   `yaml:"redirect" toml:"redirect"`
+	Log       config.Log       `yaml:"log" toml:"log"`
+	RateLimit config.RateLimit `yaml:"rateLimit" toml:"rateLimit"`
+}
+
+// PubAPIConfig is the publications API and how hard we try to reach it,
+// see the pubclient package
+type PubAPIConfig struct {
+	URL             string          `yaml:"url" toml:"url"`
+	Timeout         config.Duration `yaml:"timeout" toml:"timeout"`
+	Retries         int             `yaml:"retries" toml:"retries"`
+	BreakerFailures int             `yaml:"breakerFailures" toml:"breakerFailures"`
+	BreakerCooldown config.Duration `yaml:"breakerCooldown" toml:"breakerCooldown"`
+}
+
+// PubCacheConfig is the cache of publications fetched from the
+// publications API
+type PubCacheConfig struct {
+	Mode string `yaml:"mode" toml:"mode"` //memory, redis or off
+	TTL  uint   `yaml:"ttl" toml:"ttl"`   //seconds, if the publications API does not say
+	Size uint   `yaml:"size" toml:"size"` //only used by the memory cache
+}
+
+type ListsConfig struct {
+	ExpandWorkers int    `yaml:"expandWorkers" toml:"expandWorkers"`
+	UserHeader    string `yaml:"userHeader" toml:"userHeader"`
+	CacheToken    string `yaml:"cacheToken" toml:"cacheToken"`
+	GatewayToken  string `yaml:"gatewayToken" toml:"gatewayToken"`
+}
+
+// RedirectConfig is the policy for the paper redirect, see
+// api.RedirectPolicy
+type RedirectConfig struct {
+	Hosts   config.StringList `yaml:"hosts" toml:"hosts"`
+	Schemes config.StringList `yaml:"schemes" toml:"schemes"`
+	Status  int               `yaml:"status" toml:"status"`
+}
+
+// DefaultConfig is good for running the API on a laptop next to a redis
+// container and the publications API
+func DefaultConfig() *Config {
+	client := pubclient.DefaultConfig()
+	redirect := api.DefaultRedirectPolicy()
+	return &Config{
+		Server: config.DefaultServer(3080),
+		Cache:  config.Cache{Addr: "0.0.0.0:6379"},
+		CORS:   config.DefaultCORS(),
+		PubAPI: PubAPIConfig{
+			URL:             "http://localhost:2080",
+			Timeout:         config.Duration(client.Timeout),
+			Retries:         client.RetryCount,
+			BreakerFailures: client.BreakerFailures,
+			BreakerCooldown: config.Duration(client.BreakerCooldown),
+		},
+		PubCache: PubCacheConfig{
+			Mode: "memory",
+			TTL:  60,
+			Size: 1000,
+		},
+		Lists: ListsConfig{
+			ExpandWorkers: api.DefaultExpandWorkers,
+			UserHeader:    api.DefaultUserHeader,
+		},
+		Redirect: RedirectConfig{
+			Hosts:   redirect.Hosts,
+			Schemes: redirect.Schemes,
+			Status:  redirect.Status,
+		},
+		Log:       config.DefaultLog(),
+		RateLimit: config.DefaultRateLimit(),
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+	c.Cache.BindFlags(fs, "c", "cache")
+	c.CORS.BindFlags(fs)
+
+	fs.StringVar(&c.PubAPI.URL, "pubapi", c.PubAPI.URL, "Default endpoint for publication API")
+	fs.Var(&c.PubAPI.Timeout, "pubapi-timeout", "Timeout for each call to the publication API")
+	fs.IntVar(&c.PubAPI.Retries, "pubapi-retries", c.PubAPI.Retries, "Retries for failed calls to the publication API")
+	fs.IntVar(&c.PubAPI.BreakerFailures, "pubapi-breaker-failures", c.PubAPI.BreakerFailures, "Failures in a row that open the circuit breaker, 0 disables it")
+	fs.Var(&c.PubAPI.BreakerCooldown, "pubapi-breaker-cooldown", "How long the circuit breaker stays open")
+
+	fs.StringVar(&c.PubCache.Mode, "pubcache", c.PubCache.Mode, "Publication cache: memory, redis or off")
+	fs.UintVar(&c.PubCache.TTL, "pubcache-ttl", c.PubCache.TTL, "Seconds to cache a publication if the publication API does not say")
+	fs.UintVar(&c.PubCache.Size, "pubcache-size", c.PubCache.Size, "Max publications held by the memory publication cache")
+
+	fs.IntVar(&c.Lists.ExpandWorkers, "expand-workers", c.Lists.ExpandWorkers, "Publications fetched at the same time for ?expand=pubs")
+	fs.StringVar(&c.Lists.UserHeader, "user-header", c.Lists.UserHeader, "Header set by the gateway with the name of the caller")
+	fs.StringVar(&c.Lists.GatewayToken, "gateway-token", c.Lists.GatewayToken, "Token the gateway sends with the user header, prefer RLAPI_GATEWAY_TOKEN")
+	fs.StringVar(&c.Lists.CacheToken, "cache-token", c.Lists.CacheToken, "Bearer token for DELETE /cache/pubs/:id, prefer RLAPI_CACHE_TOKEN")
+
+	fs.Var(&c.Redirect.Hosts, "redirect-hosts", "Comma separated hosts the paper redirect may send browsers to, *.example.com allows sub domains")
+	fs.Var(&c.Redirect.Schemes, "redirect-schemes", "Comma separated URL schemes the paper redirect may use")
+	fs.IntVar(&c.Redirect.Status, "redirect-status", c.Redirect.Status, "Status code for the paper redirect, 302 or 307")
+
+	c.Log.BindFlags(fs)
+	c.RateLimit.BindFlags(fs)
+}
+
+func (c *Config) Settings() []config.Setting {
+	settings := config.ServerSettings("RLAPI_")
+	settings = append(settings, config.CacheSettings("c", "cache", "RLAPI_CACHE_")...)
+	settings = append(settings, config.CORSSettings("RLAPI_")...)
+	settings = append(settings,
+		config.Setting{Flag: "pubapi", Env: "RLAPI_PUB_API_URL"},
+		config.Setting{Flag: "pubapi-timeout", Env: "RLAPI_PUB_API_TIMEOUT"},
+		config.Setting{Flag: "pubapi-retries", Env: "RLAPI_PUB_API_RETRIES"},
+		config.Setting{Flag: "pubapi-breaker-failures", Env: "RLAPI_PUB_API_BREAKER_FAILURES"},
+		config.Setting{Flag: "pubapi-breaker-cooldown", Env: "RLAPI_PUB_API_BREAKER_COOLDOWN"},
+		config.Setting{Flag: "pubcache", Env: "RLAPI_PUB_CACHE"},
+		config.Setting{Flag: "pubcache-ttl", Env: "RLAPI_PUB_CACHE_TTL"},
+		config.Setting{Flag: "pubcache-size", Env: "RLAPI_PUB_CACHE_SIZE"},
+		config.Setting{Flag: "expand-workers", Env: "RLAPI_EXPAND_WORKERS"},
+		config.Setting{Flag: "user-header", Env: "RLAPI_USER_HEADER"},
+		config.Setting{Flag: "cache-token", Env: "RLAPI_CACHE_TOKEN"},
+		config.Setting{Flag: "gateway-token", Env: "RLAPI_GATEWAY_TOKEN"},
+		config.Setting{Flag: "redirect-hosts", Env: "RLAPI_REDIRECT_HOSTS"},
+		config.Setting{Flag: "redirect-schemes", Env: "RLAPI_REDIRECT_SCHEMES"},
+		config.Setting{Flag: "redirect-status", Env: "RLAPI_REDIRECT_STATUS"},
+	)
+	settings = append(settings, config.LogSettings("RLAPI_")...)
+	return append(settings, config.RateLimitSettings("RLAPI_")...)
+}
+
+func (c *Config) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	c.Cache.Check(&p)
+	c.CORS.Check(&p)
+
+	config.CheckURL(&p, "publications API url", c.PubAPI.URL)
+	p.Check(c.PubAPI.Timeout > 0, "publications API timeout must be more than 0")
+	p.Check(c.PubAPI.Retries >= 0, "publications API retries can't be negative")
+	p.Check(c.PubAPI.BreakerFailures >= 0, "publications API breaker failures can't be negative")
+	p.Check(c.PubAPI.BreakerCooldown > 0, "publications API breaker cooldown must be more than 0")
+
+	p.Check(config.OneOf(c.PubCache.Mode, "memory", "redis", "off"), "publication cache %q must be memory, redis or off", c.PubCache.Mode)
+	p.Check(c.PubCache.TTL > 0, "publication cache ttl must be more than 0")
+	p.Check(c.PubCache.Size > 0, "publication cache size must be more than 0")
+
+	p.Check(c.Lists.ExpandWorkers > 0, "expand workers must be more than 0")
+	p.Check(c.Lists.UserHeader != "", "user header is required")
+	//without the token anyone that can reach the API could say they are
+	//any user, so the API does not start without one
+	p.Check(len(c.Lists.GatewayToken) >= 32, "gateway token of at least 32 characters is required, the %s header is only trusted from requests that send it", c.Lists.UserHeader)
+	if err := c.RedirectPolicy().Validate(); err != nil {
+		p.Check(false, "%v", err)
+	}
+
+	c.Log.Check(&p)
+	c.RateLimit.Check(&p)
+	return p.Err()
+}
+
+// PubClientConfig is the pubclient config with the settings that are not
+// in the file left at their defaults
+func (c *Config) PubClientConfig() pubclient.Config {
+	client := pubclient.DefaultConfig()
+	client.Timeout = c.PubAPI.Timeout.Std()
+	client.RetryCount = c.PubAPI.Retries
+	client.BreakerFailures = c.PubAPI.BreakerFailures
+	client.BreakerCooldown = c.PubAPI.BreakerCooldown.Std()
+	return client
+}
+
+func (c *Config) RedirectPolicy() api.RedirectPolicy {
+	return api.RedirectPolicy{
+		Hosts:   c.Redirect.Hosts,
+		Schemes: c.Redirect.Schemes,
+		Status:  c.Redirect.Status,
+	}
+}
+
+// String is the config as YAML without the cache token and the cache
+// password, it is logged when the API starts
+func (c *Config) String() string {
+	r := *c
+	r.Cache = r.Cache.Redacted()
+	r.Lists.CacheToken = config.Redact(r.Lists.CacheToken)
+	r.Lists.GatewayToken = config.Redact(r.Lists.GatewayToken)
+	return config.String(r)
+}
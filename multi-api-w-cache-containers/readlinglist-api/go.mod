@@ -1,15 +1,21 @@
 module architectingsoftware.com/reading-list-api
 
-go 1.20
+go 1.21
 
 require (
+	architectingsoftware.com/httpkit v1.0.0
+	architectingsoftware.com/pubschema v1.0.0
 	github.com/gin-contrib/cors v1.4.0
 	github.com/go-redis/redis/v8 v8.11.5
+	github.com/stretchr/testify v1.8.4
 )
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 require (
@@ -35,3 +41,7 @@ require (
 	google.golang.org/protobuf v1.28.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+replace architectingsoftware.com/pubschema => ../pubschema
+
+replace architectingsoftware.com/httpkit => ../../httpkit
@@ -1,83 +1,199 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/httpkit/config"
 	"architectingsoftware.com/reading-list-api/api"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag  string
-	portFlag  uint
-	cacheURL  string
-	pubAPIURL string
-)
-
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.StringVar(&pubAPIURL, "pubapi", "http://localhost:2080", "Default endpoint for publication API")
-	flag.StringVar(&cacheURL, "c", "0.0.0.0:6379", "Default cache location")
-	flag.UintVar(&portFlag, "p", 3080, "Default Port")
-
-	flag.Parse()
-}
-
-func envVarOrDefault(envVar string, defaultVal string) string {
-	envVal := os.Getenv(envVar)
-	if envVal != "" {
-		return envVal
+func main() {
+	//the defaults, a config file, the environment and the command line
+	//flags, see config.go
+	cfg := DefaultConfig()
+	if err := config.Load(cfg, os.Args[1:], "RLAPI_CONFIG"); err != nil {
+		fmt.Println("Error loading config:", err)
+		os.Exit(1)
 	}
-	return defaultVal
-}
-
-func setupParms() {
-	//first process any command line flags
-	processCmdLineFlags()
+	accessLogger, accessLevel, err := setupLogging(cfg.Log)
+	if err != nil {
+		fmt.Println("Error setting up logging:", err)
+		os.Exit(1)
+	}
+	slog.Info("Init", "config", cfg.String())
 
-	//now process any environment variables
-	cacheURL = envVarOrDefault("RLAPI_CACHE_URL", cacheURL)
-	pubAPIURL = envVarOrDefault("RLAPI_PUB_API_URL", pubAPIURL)
-	hostFlag = envVarOrDefault("RLAPI_HOST", hostFlag)
+	redisOpts, err := cfg.Cache.RedisOptions()
+	if err != nil {
+		fmt.Println("Error setting up redis:", err)
+		os.Exit(1)
+	}
+	apiHandler, err := api.NewReadingListAPI(redisOpts, cfg.PubAPI.URL, cfg.PubClientConfig())
 
-	pfNew, err := strconv.Atoi(envVarOrDefault("RLAPI_PORT", fmt.Sprintf("%d", portFlag)))
-	//only update the port if we were able to convert the env var to an int, else
-	//we will use the default we got from the command line, or command line defaults
-	if err == nil {
-		portFlag = uint(pfNew)
+	if err != nil {
+		panic(err)
 	}
 
-}
+	if cfg.PubCache.Mode != "off" {
+		//keep serving cached publications for up to 10 minutes past when they
+		//expire if the publication API can not be reached
+		ttl := time.Duration(cfg.PubCache.TTL) * time.Second
+		err = apiHandler.EnablePubCache(cfg.PubCache.Mode, ttl, 10*time.Minute, int(cfg.PubCache.Size))
+		if err != nil {
+			panic(err)
+		}
+	}
 
-func main() {
-	//this will allow the user to override key parameters and also setup defaults
-	setupParms()
-	log.Println("Init/cacheURL: " + cacheURL)
-	log.Println("Init/pubAPIURL: " + pubAPIURL)
-	log.Println("Init/hostFlag: " + hostFlag)
-	log.Printf("Init/portFlag: %d", portFlag)
+	apiHandler.SetExpandWorkers(cfg.Lists.ExpandWorkers)
+	apiHandler.SetUserHeader(cfg.Lists.UserHeader)
+	apiHandler.SetCacheToken(cfg.Lists.CacheToken)
+	apiHandler.SetGatewayToken(cfg.Lists.GatewayToken)
 
-	apiHandler, err := api.NewReadingListAPI(cacheURL, pubAPIURL)
+	if err := apiHandler.SetRedirectPolicy(cfg.RedirectPolicy()); err != nil {
+		panic(err)
+	}
 
+	//gin.Default() with our own access log, every line has the request
+	//id so it can be matched up with the lines from the other API
+	r := gin.New()
+	r.Use(httpkit.RequestID())
+	if accessLogger != nil {
+		r.Use(httpkit.AccessLog(accessLogger, accessLevel))
+	}
+	//browsers can call the API from -cors-origins, and can see which
+	//publications were left out of a bibliography
+	corsCfg := httpkit.CORSConfig(cfg.CORS.Origins)
+	corsCfg.ExposeHeaders = append(corsCfg.ExposeHeaders, "X-Missing-Items")
+	r.Use(gin.Recovery(), cors.New(corsCfg))
+	r.NoRoute(httpkit.NoRoute)
+
+	//every caller gets a bucket of requests for each route, see httpkit.
+	//It runs before any handler so callers that are turned away, like
+	//ones with a bad token, are counted too
+	rateStore, err := httpkit.NewRateStore(cfg.RateLimit.Store, redisOpts)
 	if err != nil {
-		panic(err)
+		fmt.Println("Error setting up rate limiting:", err)
+		os.Exit(1)
+	}
+	var limiter *httpkit.RateLimiter
+	if rateStore != nil {
+		limiter = httpkit.NewRateLimiter(rateStore, httpkit.PerMinute(cfg.RateLimit.Default))
+		r.Use(limiter.Handler(httpkit.Limit{}))
 	}
 
-	r := gin.Default()
-	r.Use(cors.Default())
+	//the user header is only trusted when the gateway sent it
+	r.Use(apiHandler.CheckUser())
 
 	r.GET("/publists", apiHandler.GetReadingLists)
 	r.GET("/publists/:id", apiHandler.GetReadingList)
+	r.GET("/publists/:id/stats", apiHandler.GetReadingListStats)
 	r.GET("/publists/:id/:idx", apiHandler.GetPubFromReadingList)
 	r.GET("/publists/:id/:idx/paper", apiHandler.RedirectWithPublication)
+	r.POST("/publists", apiHandler.AddReadingList)
+	r.PATCH("/publists/:id", apiHandler.UpdateReadingList)
+	r.DELETE("/publists/:id", apiHandler.DeleteReadingList)
+	r.POST("/publists/:id/items", apiHandler.AddReadingListItem)
+	r.PUT("/publists/:id/order", apiHandler.ReorderReadingList)
+	r.PUT("/publists/:id/:idx/progress", apiHandler.UpdateItemProgress)
+	r.DELETE("/publists/:id/:idx", apiHandler.DeleteReadingListItem)
+	r.DELETE("/cache/pubs/:id", apiHandler.InvalidatePublication)
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+	if err := serve(srv, cfg.Server.ShutdownGrace.Std()); err != nil {
+		slog.Error("Server stopped with an error", "error", err)
+	}
+
+	//The server has stopped so nothing is using redis anymore
+	if limiter != nil {
+		if err := limiter.Close(); err != nil {
+			slog.Error("Error closing rate limiter", "error", err)
+		}
+	}
+	if err := apiHandler.Close(); err != nil {
+		slog.Error("Error closing redis", "error", err)
+	}
+	slog.Info("Stopped")
+}
+
+// setupLogging makes the JSON or text logger the default for slog and for
+// the log package, and builds the access logger.  The access logger is
+// nil when the access log is off.  It is not filtered by the log level,
+// its lines are never below accessLevel so every request is logged
+func setupLogging(logCfg config.Log) (accessLogger *slog.Logger, accessLevel slog.Level, err error) {
+	level, err := httpkit.ParseLogLevel(logCfg.Level)
+	if err != nil {
+		return nil, 0, err
+	}
+	logger, err := httpkit.NewLogger(os.Stdout, logCfg.Format, level)
+	if err != nil {
+		return nil, 0, err
+	}
+	slog.SetDefault(logger)
+
+	//gin prints every route when it is in debug mode, that is only
+	//wanted when we are debugging
+	if level > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-	//For now we will just support gets
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	accessLevel, err = httpkit.ParseLogLevel(logCfg.AccessLevel)
+	if err != nil {
+		return nil, 0, fmt.Errorf("access log: %w", err)
+	}
+	if logCfg.AccessFormat == "off" {
+		return nil, accessLevel, nil
+	}
+	accessLogger, err = httpkit.NewLogger(os.Stdout, logCfg.AccessFormat, accessLevel)
+	if err != nil {
+		return nil, 0, fmt.Errorf("access log: %w", err)
+	}
+	return accessLogger, accessLevel, nil
+}
+
+// serve runs the server until it gets SIGINT or SIGTERM, which is what
+// docker and kubernetes send to stop a container.  It then stops taking
+// new connections and gives the requests that are running the grace
+// period to finish
+func serve(srv *http.Server, grace time.Duration) error {
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		//the server could not start, for example the port is in use
+		return err
+	case <-stopCtx.Done():
+	}
 
+	slog.Info("Shutting down, waiting for requests to finish", "grace", grace)
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("requests did not finish in time: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
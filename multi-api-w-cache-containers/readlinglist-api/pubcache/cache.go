@@ -0,0 +1,138 @@
+package pubcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/pubclient"
+	"architectingsoftware.com/reading-list-api/schema"
+)
+
+var ErrPubNotFound = errors.New("publication not found")
+
+// PubCache is a read-through cache in front of the publications API.
+// Publications are cached for as long as the publications API says they
+// are fresh with Cache-Control: max-age, or for the default ttl if it does
+// not say.  Once an entry is stale it is revalidated with its ETag, and if
+// the publications API can not be reached a stale entry is still returned
+// for up to staleFor so reading lists keep working through short outages
+type PubCache struct {
+	store      Store
+	apiClient  *pubclient.Client
+	defaultTTL time.Duration
+	staleFor   time.Duration
+}
+
+func New(store Store, apiClient *pubclient.Client, defaultTTL, staleFor time.Duration) *PubCache {
+	return &PubCache{
+		store:      store,
+		apiClient:  apiClient,
+		defaultTTL: defaultTTL,
+		staleFor:   staleFor,
+	}
+}
+
+// GetPublication returns the publication at location, which is a path in
+// the publications API such as /pubs/10
+func (pc *PubCache) GetPublication(ctx context.Context, location string) (schema.Publication, error) {
+	id := PubIDFromLocation(location)
+	cached, found := pc.store.Get(id)
+	if found && time.Now().Before(cached.Expires) {
+		return cached.Pub, nil
+	}
+
+	headers := map[string]string{}
+	if found && cached.ETag != "" {
+		headers["If-None-Match"] = cached.ETag
+	}
+
+	resp, err := pc.apiClient.Get(ctx, location, headers)
+	if err != nil {
+		return pc.serveStale(ctx, cached, found, err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusNotModified:
+		if found {
+			ttl, cacheable := pc.freshness(resp.Header())
+			if cacheable {
+				cached.Expires = time.Now().Add(ttl)
+				pc.store.Set(id, cached)
+			}
+			return cached.Pub, nil
+		}
+		return schema.Publication{}, fmt.Errorf("publication API returned 304 for uncached %s", location)
+	case http.StatusOK:
+		var pub schema.Publication
+		if err := json.Unmarshal(resp.Body(), &pub); err != nil {
+			return schema.Publication{}, err
+		}
+		ttl, cacheable := pc.freshness(resp.Header())
+		if cacheable {
+			pc.store.Set(id, Entry{
+				Pub:     pub,
+				ETag:    resp.Header().Get("ETag"),
+				Expires: time.Now().Add(ttl),
+			})
+		} else {
+			pc.store.Delete(id)
+		}
+		return pub, nil
+	case http.StatusNotFound:
+		pc.store.Delete(id)
+		return schema.Publication{}, ErrPubNotFound
+	default:
+		return pc.serveStale(ctx, cached, found, pubclient.NewStatusError(resp))
+	}
+}
+
+// Invalidate removes a publication from the cache so the next read goes to
+// the publications API, call it when a publication is known to have changed
+func (pc *PubCache) Invalidate(id string) {
+	pc.store.Delete(id)
+}
+
+func (pc *PubCache) serveStale(ctx context.Context, cached Entry, found bool, err error) (schema.Publication, error) {
+	if found && time.Now().Before(cached.Expires.Add(pc.staleFor)) {
+		slog.WarnContext(ctx, "Serving stale publication", "id", cached.Pub.ID, "error", err)
+		return cached.Pub, nil
+	}
+	return schema.Publication{}, err
+}
+
+// freshness works out how long a response can be cached for from its
+// Cache-Control header.  no-store means it can not be cached at all, and
+// no-cache means it has to be revalidated every time
+func (pc *PubCache) freshness(h http.Header) (time.Duration, bool) {
+	ttl := pc.defaultTTL
+	noCache := false
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store":
+			return 0, false
+		case directive == "no-cache":
+			noCache = true
+		case strings.HasPrefix(directive, "max-age="):
+			if secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && secs >= 0 {
+				ttl = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	if noCache {
+		return 0, true
+	}
+	return ttl, true
+}
+
+// PubIDFromLocation turns a location like /pubs/10 into the id 10
+func PubIDFromLocation(location string) string {
+	return location[strings.LastIndex(location, "/")+1:]
+}
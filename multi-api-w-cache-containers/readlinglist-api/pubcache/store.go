@@ -0,0 +1,145 @@
+package pubcache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/go-redis/redis/v8"
+)
+
+// Entry is a cached publication along with the validator and freshness
+// information the publications API sent with it
+type Entry struct {
+	Pub     schema.Publication `json:"pub"`
+	ETag    string             `json:"etag,omitempty"`
+	Expires time.Time          `json:"expires"`
+}
+
+// Store is where cached publications are kept, keys are publication ids.
+// Entries are kept past their Expires time so that they can be revalidated
+// with the ETag, or served stale if the publications API is down
+type Store interface {
+	Get(id string) (Entry, bool)
+	Set(id string, e Entry)
+	Delete(id string)
+}
+
+//------------------------------------------------------------
+// IN MEMORY LRU STORE
+//------------------------------------------------------------
+
+type lruItem struct {
+	id    string
+	entry Entry
+}
+
+// MemoryStore is an in memory least recently used cache, once it holds
+// size entries adding another one drops the entry used the longest ago
+type MemoryStore struct {
+	lock  sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List //front is most recently used
+}
+
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{
+		size:  size,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (m *MemoryStore) Get(id string) (Entry, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	el, ok := m.items[id]
+	if !ok {
+		return Entry{}, false
+	}
+	m.order.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+func (m *MemoryStore) Set(id string, e Entry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if el, ok := m.items[id]; ok {
+		el.Value.(*lruItem).entry = e
+		m.order.MoveToFront(el)
+		return
+	}
+
+	m.items[id] = m.order.PushFront(&lruItem{id: id, entry: e})
+	for m.size > 0 && m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*lruItem).id)
+	}
+}
+
+func (m *MemoryStore) Delete(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if el, ok := m.items[id]; ok {
+		m.order.Remove(el)
+		delete(m.items, id)
+	}
+}
+
+//------------------------------------------------------------
+// REDIS STORE
+//------------------------------------------------------------
+
+const (
+	RedisKeyPrefix = "pubcache:"
+)
+
+// RedisStore keeps cached publications in redis so that every replica of
+// the reading list API shares them.  Redis drops entries on its own once
+// they are older than retain
+type RedisStore struct {
+	client  *redis.Client
+	context context.Context
+	retain  time.Duration
+}
+
+func NewRedisStore(ctx context.Context, client *redis.Client, retain time.Duration) *RedisStore {
+	return &RedisStore{
+		client:  client,
+		context: ctx,
+		retain:  retain,
+	}
+}
+
+func (rs *RedisStore) Get(id string) (Entry, bool) {
+	b, err := rs.client.Get(rs.context, RedisKeyPrefix+id).Bytes()
+	if err != nil {
+		return Entry{}, false
+	}
+
+	var e Entry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+func (rs *RedisStore) Set(id string, e Entry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	rs.client.Set(rs.context, RedisKeyPrefix+id, b, rs.retain)
+}
+
+func (rs *RedisStore) Delete(id string) {
+	rs.client.Del(rs.context, RedisKeyPrefix+id)
+}
@@ -0,0 +1,93 @@
+package pubclient
+
+import (
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota //requests flow normally
+	breakerOpen                         //requests fail right away
+	breakerHalfOpen                     //one trial request is allowed through
+)
+
+// breaker is a simple circuit breaker.  After threshold failures in a row
+// it opens and fails every request without calling the publications API.
+// Once cooldown has passed it lets a single trial request through, if that
+// works the breaker closes again, otherwise it stays open for another
+// cooldown
+type breaker struct {
+	lock      sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	threshold int
+	cooldown  time.Duration
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		state:     breakerClosed,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports if a request may be sent
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true //breaker disabled
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		//a trial request is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+func (b *breaker) failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// cancelled is called when our caller gave up on a request, which says
+// nothing about the publications API so it is not a failure.  If it was
+// the trial request the breaker goes back to open for another cooldown,
+// otherwise no trial would ever be let through again
+func (b *breaker) cancelled() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
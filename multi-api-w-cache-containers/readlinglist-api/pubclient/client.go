@@ -0,0 +1,203 @@
+package pubclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/go-resty/resty/v2"
+)
+
+var (
+	// ErrCircuitOpen is returned without calling the publications API
+	// while the circuit breaker is open
+	ErrCircuitOpen = errors.New("publication API circuit breaker is open")
+
+	// ErrBadLocation is returned for a location that is not the path of a
+	// publication, nothing is sent for it
+	ErrBadLocation = errors.New("location must look like /pubs/<id>")
+)
+
+// locationPath is the only kind of path the reading list API asks the
+// publications API for
+var locationPath = regexp.MustCompile(`^/pubs/\d+$`)
+
+// StatusError is returned when the publications API answers with a status
+// the caller did not expect, for example a 500 that did not go away after
+// retrying
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Detail     string //from the problem in the body, if there is one
+}
+
+func (e *StatusError) Error() string {
+	if e.Detail != "" {
+		return "publication API returned " + e.Status + ": " + e.Detail
+	}
+	return "publication API returned " + e.Status
+}
+
+// Config controls how the reading list API talks to the publications API
+type Config struct {
+	Timeout         time.Duration //per attempt
+	RetryCount      int           //extra attempts for failed GETs
+	RetryWait       time.Duration //starting backoff, doubled each retry with jitter
+	RetryMaxWait    time.Duration
+	BreakerFailures int //failures in a row that open the breaker, 0 disables it
+	BreakerCooldown time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:         2 * time.Second,
+		RetryCount:      2,
+		RetryWait:       100 * time.Millisecond,
+		RetryMaxWait:    time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+	}
+}
+
+// Client is a resty client for the publications API with timeouts,
+// retries and a circuit breaker.  It only supports GET because those are
+// the only calls the reading list API makes, and GETs are safe to retry
+type Client struct {
+	restClient *resty.Client
+	baseURL    *url.URL
+	breaker    *breaker
+}
+
+// New returns a client for the publications API at baseURL, which has to
+// be an absolute http or https URL
+func New(baseURL string, cfg Config) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("publications API url %q must look like http://host:port", baseURL)
+	}
+
+	restClient := resty.New().
+		SetTimeout(cfg.Timeout).
+		SetRetryCount(cfg.RetryCount).
+		SetRetryWaitTime(cfg.RetryWait).
+		SetRetryMaxWaitTime(cfg.RetryMaxWait).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if resp != nil && resp.Request != nil && resp.Request.Method != http.MethodGet {
+				return false
+			}
+			//retry on network errors and on errors from the server side
+			return err != nil || resp.StatusCode() >= http.StatusInternalServerError
+		})
+
+	return &Client{
+		restClient: restClient,
+		baseURL:    base,
+		breaker:    newBreaker(cfg.BreakerFailures, cfg.BreakerCooldown),
+	}, nil
+}
+
+// Close closes the idle connections to the publications API
+func (c *Client) Close() {
+	c.restClient.GetClient().CloseIdleConnections()
+}
+
+// Get requests location, a path such as /pubs/10, from the publications
+// API.  Locations come from the callers of the reading list API, anything
+// else, like @evil.host/x, is ErrBadLocation and is not requested.  Transport errors and 5xx responses, once the retries are used up,
+// are returned as errors and count as failures for the circuit breaker.
+// Any other response is returned for the caller to interpret.  The call
+// is cancelled with ctx, and the request id in ctx is sent along so the
+// publications API logs it too
+func (c *Client) Get(ctx context.Context, location string, headers map[string]string) (*resty.Response, error) {
+	u, err := c.resolve(location)
+	if err != nil {
+		return nil, err
+	}
+	if !c.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	req := c.restClient.R().SetContext(ctx).SetHeaders(headers)
+	if id := httpkit.RequestIDFromContext(ctx); id != "" {
+		req.SetHeader(httpkit.RequestIDHeader, id)
+	}
+	resp, err := req.Get(u)
+	if err != nil {
+		//our caller giving up says nothing about the publications API
+		if ctx.Err() != nil {
+			c.breaker.cancelled()
+		} else {
+			c.breaker.failure()
+		}
+		return nil, err
+	}
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		c.breaker.failure()
+		return nil, NewStatusError(resp)
+	}
+
+	c.breaker.success()
+	return resp, nil
+}
+
+func NewStatusError(resp *resty.Response) *StatusError {
+	se := &StatusError{
+		StatusCode: resp.StatusCode(),
+		Status:     resp.Status(),
+	}
+	//errors from the publications API are problem details, see httpkit
+	if strings.HasPrefix(resp.Header().Get("Content-Type"), httpkit.ProblemContentType) {
+		var p httpkit.Problem
+		if json.Unmarshal(resp.Body(), &p) == nil {
+			se.Detail = p.Detail
+		}
+	}
+	return se
+}
+
+// URL returns the full URL for a location, it is used in error messages
+func (c *Client) URL(location string) string {
+	u, err := c.resolve(location)
+	if err != nil {
+		return location
+	}
+	return u
+}
+
+// CheckLocation returns ErrBadLocation if location is not the path of a
+// publication, like /pubs/10
+func CheckLocation(location string) error {
+	u, err := url.Parse(location)
+	//the parsed path has to be all there is, so there is no room for a
+	//host, a user, a query or an escaped path
+	if err != nil || u.String() != location || u.Path != location || !locationPath.MatchString(u.Path) {
+		return fmt.Errorf("%w, not %q", ErrBadLocation, location)
+	}
+	return nil
+}
+
+// resolve builds the URL for a location on the publications API, the
+// location is only ever a path under the base URL
+func (c *Client) resolve(location string) (string, error) {
+	if err := CheckLocation(location); err != nil {
+		return "", err
+	}
+	return c.baseURL.JoinPath(location).String(), nil
+}
+
+// IsTimeout reports if err came from the publications API taking too long
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -1,2 +1,2 @@
 #!/bin/bash
-docker run --name cnse-publist-api --rm -e RLAPI_PUB_API_URL=http://host.docker.internal:2080 -p 3080:3080 architectingsoftware/cnse-publist-api:v1
+docker run --name cnse-publist-api --rm -e RLAPI_PUB_API_URL=http://host.docker.internal:2080 -e RLAPI_GATEWAY_TOKEN=${PUBLIST_GATEWAY_TOKEN:?set PUBLIST_GATEWAY_TOKEN} -p 3080:3080 architectingsoftware/cnse-publist-api:v1
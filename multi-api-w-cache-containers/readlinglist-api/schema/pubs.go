@@ -1,21 +1,144 @@
 package schema
 
-type slideLink struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
-	Link        string `json:"link"`
+import (
+	"sort"
+	"time"
+
+	"architectingsoftware.com/pubschema"
+)
+
+// The publication types are shared with the publications API, see the
+// pubschema module
+type Publication = pubschema.Publication
+type SlideLink = pubschema.SlideLink
+
+// ReadingList maps an item key, for example "JSC07", to the relative URL
+// of the publication in the publications API.  Maps do not keep an order
+// so Order lists the item keys in the order they should be read
+//
+// A reading list belongs to the user that created it.  Private lists can
+// only be seen by their owner, shared lists can be seen by anyone but only
+// changed by their owner.  Lists without an owner were created before
+// owners existed, anyone can see and change them
+type ReadingList struct {
+	ID          int                     `json:"id"`
+	Description string                  `json:"description"`
+	Items       map[string]string       `json:"items,omitempty"`
+	Order       []string                `json:"order,omitempty"`
+	Owner       string                  `json:"owner,omitempty"`
+	Visibility  string                  `json:"visibility,omitempty"`
+	Progress    map[string]ItemProgress `json:"progress,omitempty"` //keyed by item key, missing means unread
 }
-type Publication struct {
-	ID       int         `json:"id"`
-	Title    string      `json:"title"`
-	Cite     string      `json:"cite"`
-	Link     string      `json:"link,omitempty"`
-	Slides   []slideLink `json:"slides,omitempty"`
-	Abstract string      `json:"abstract"`
+
+const (
+	VisibilityPrivate = "private"
+	VisibilityShared  = "shared"
+)
+
+// The reading states of an item in a reading list
+const (
+	StateUnread     = "unread"
+	StateInProgress = "in-progress"
+	StateRead       = "read"
+)
+
+// ItemProgress is how far the owner of a reading list has got with one of
+// its items, along with their notes
+type ItemProgress struct {
+	State     string     `json:"state"`
+	Notes     string     `json:"notes,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
 }
 
-type ReadingList struct {
-	ID          int               `json:"id"`
-	Description string            `json:"description"`
-	Items       map[string]string `json:"items,omitempty"`
+func IsValidState(state string) bool {
+	return state == StateUnread || state == StateInProgress || state == StateRead
+}
+
+func IsValidVisibility(visibility string) bool {
+	return visibility == VisibilityPrivate || visibility == VisibilityShared
+}
+
+// CanRead reports if user can see the reading list, user is "" for
+// requests that did not say who they are from
+func (rl *ReadingList) CanRead(user string) bool {
+	return rl.Owner == "" || rl.Visibility != VisibilityPrivate || rl.Owner == user
+}
+
+// CanChange reports if user can change the reading list
+func (rl *ReadingList) CanChange(user string) bool {
+	return rl.Owner == "" || rl.Owner == user
+}
+
+// ItemProgress returns the progress for an item, items that were never
+// started are unread
+func (rl *ReadingList) ItemProgress(key string) ItemProgress {
+	if p, ok := rl.Progress[key]; ok {
+		return p
+	}
+	return ItemProgress{State: StateUnread}
+}
+
+// ExpandedItem is a reading list item along with the publication it points
+// to.  If the publication could not be loaded Error and Status explain why
+type ExpandedItem struct {
+	Key         string       `json:"key"`
+	Location    string       `json:"location"`
+	Publication *Publication `json:"publication,omitempty"`
+	Status      int          `json:"status,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Progress    ItemProgress `json:"progress"`
+}
+
+// ExpandedReadingList is returned by GET /publists/:id?expand=pubs, the
+// publications are listed in reading order
+type ExpandedReadingList struct {
+	ReadingList
+	Publications []ExpandedItem `json:"publications"`
+}
+
+// ReadingListStats is returned by GET /publists/:id/stats, Items is
+// keyed by the item key
+type ReadingListStats struct {
+	ID    int                  `json:"id"`
+	Total int64                `json:"total"`
+	Items map[string]ItemStats `json:"items"`
+}
+
+// ItemStats counts how many times the paper for a reading list item was
+// opened from this reading list (Clicks) and from any reading list
+// (PubClicks)
+type ItemStats struct {
+	Location  string `json:"location"`
+	Clicks    int64  `json:"clicks"`
+	PubClicks int64  `json:"pubClicks"`
+}
+
+// ReadingListItem is the body used to add a publication to a reading list
+type ReadingListItem struct {
+	Key   string `json:"key"`
+	PubID int    `json:"pubId"`
+}
+
+// NormalizeOrder makes sure that Order lists every item exactly once.
+// Reading lists that were loaded before Order existed get their keys in
+// sorted order
+func (rl *ReadingList) NormalizeOrder() {
+	order := make([]string, 0, len(rl.Items))
+	seen := make(map[string]bool)
+	for _, key := range rl.Order {
+		if _, ok := rl.Items[key]; ok && !seen[key] {
+			order = append(order, key)
+			seen[key] = true
+		}
+	}
+
+	var missing []string
+	for key := range rl.Items {
+		if !seen[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	rl.Order = append(order, missing...)
 }
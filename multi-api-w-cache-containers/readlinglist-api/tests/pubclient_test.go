@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/pubclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cooldown = 50 * time.Millisecond
+
+// newPubAPI is a publications API that answers with the status in status,
+// or waits for the caller to give up if it is 0
+func newPubAPI(t *testing.T, status *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(status.Load())
+		if code == 0 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(t *testing.T, url string) *pubclient.Client {
+	cfg := pubclient.DefaultConfig()
+	cfg.RetryCount = 0
+	cfg.BreakerFailures = 1
+	cfg.BreakerCooldown = cooldown
+	client, err := pubclient.New(url, cfg)
+	require.NoError(t, err)
+	return client
+}
+
+func TestBreakerOpensAndCloses(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	client := newClient(t, newPubAPI(t, &status).URL)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, "/pubs/1", nil)
+	var se *pubclient.StatusError
+	assert.ErrorAs(t, err, &se)
+
+	_, err = client.Get(ctx, "/pubs/1", nil)
+	assert.ErrorIs(t, err, pubclient.ErrCircuitOpen)
+
+	//after the cooldown a trial goes through, and closes the breaker
+	status.Store(http.StatusOK)
+	time.Sleep(cooldown)
+	resp, err := client.Get(ctx, "/pubs/1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	_, err = client.Get(ctx, "/pubs/1", nil)
+	assert.NoError(t, err)
+}
+
+func TestBreakerCancelledTrial(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	client := newClient(t, newPubAPI(t, &status).URL)
+
+	_, err := client.Get(context.Background(), "/pubs/1", nil)
+	require.Error(t, err)
+
+	//the trial request is cancelled by our caller
+	status.Store(0)
+	time.Sleep(cooldown)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.Get(ctx, "/pubs/1", nil)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, pubclient.ErrCircuitOpen)
+
+	//the breaker is open again for a cooldown, and then lets a new trial
+	//through instead of staying half open for good
+	status.Store(http.StatusOK)
+	_, err = client.Get(context.Background(), "/pubs/1", nil)
+	assert.ErrorIs(t, err, pubclient.ErrCircuitOpen)
+	time.Sleep(cooldown)
+	_, err = client.Get(context.Background(), "/pubs/1", nil)
+	assert.NoError(t, err)
+}
+
+func TestCancelledRequestsAreNotFailures(t *testing.T) {
+	var status atomic.Int32
+	client := newClient(t, newPubAPI(t, &status).URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.Get(ctx, "/pubs/1", nil)
+	require.Error(t, err)
+
+	status.Store(http.StatusOK)
+	_, err = client.Get(context.Background(), "/pubs/1", nil)
+	assert.NoError(t, err)
+}
+
+// hostileLocations would send the request somewhere other than a
+// publication on the publications API if they were added to its URL
+var hostileLocations = []string{
+	"@evil.host/x",
+	".evil.host/",
+	"//evil.host/pubs/1",
+	"http://evil.host/pubs/1",
+	"/pubs/1@evil.host",
+	"/pubs/../admin",
+	"/pubs/%31",
+	"/pubs/1?id=2",
+	"/pubs/1#x",
+	"/pubs/1/",
+	"/pubs/-1",
+	"/pubs/",
+	"pubs/1",
+	"",
+}
+
+func TestGetRejectsHostileLocations(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+	client := newClient(t, srv.URL)
+
+	for _, location := range hostileLocations {
+		_, err := client.Get(context.Background(), location, nil)
+		assert.ErrorIs(t, err, pubclient.ErrBadLocation, location)
+		assert.ErrorIs(t, pubclient.CheckLocation(location), pubclient.ErrBadLocation, location)
+	}
+	assert.Zero(t, hits.Load())
+
+	//bad locations don't count against the breaker
+	_, err := client.Get(context.Background(), "/pubs/1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestGetJoinsTheBaseURL(t *testing.T) {
+	var path atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path.Store(r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := newClient(t, srv.URL+"/v1/")
+	_, err := client.Get(context.Background(), "/pubs/10", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/pubs/10", path.Load())
+	assert.Equal(t, srv.URL+"/v1/pubs/10", client.URL("/pubs/10"))
+}
+
+func TestNewNeedsAnAbsoluteURL(t *testing.T) {
+	for _, url := range []string{"", "localhost:2080", "/pubs", "ftp://host"} {
+		_, err := pubclient.New(url, pubclient.DefaultConfig())
+		assert.Error(t, err, url)
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/reading-list-api/api"
+	"architectingsoftware.com/reading-list-api/pubclient"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatewayToken is what the gateway in front of the API would send
+const gatewayToken = "a-gateway-token-only-for-the-tests"
+
+// newRouter is the reading list API in front of a fake redis and a
+// publications API that counts its hits and knows every publication
+func newRouter(t *testing.T, hits *atomic.Int32) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	pubAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1, "title": "Architecting Software"}`))
+	}))
+	t.Cleanup(pubAPI.Close)
+
+	cfg := pubclient.DefaultConfig()
+	cfg.RetryCount = 0
+	apiHandler, err := api.NewReadingListAPI(startFakeRedis(t), pubAPI.URL, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { apiHandler.Close() })
+	apiHandler.SetGatewayToken(gatewayToken)
+
+	r := gin.New()
+	r.Use(apiHandler.CheckUser())
+	r.GET("/publists/:id", apiHandler.GetReadingList)
+	r.POST("/publists", apiHandler.AddReadingList)
+	r.POST("/publists/:id/items", apiHandler.AddReadingListItem)
+	return r
+}
+
+func send(r *gin.Engine, method, path, body string, headers map[string]string) (*httptest.ResponseRecorder, httpkit.Problem) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(w, req)
+
+	var p httpkit.Problem
+	json.Unmarshal(w.Body.Bytes(), &p)
+	return w, p
+}
+
+func TestAddReadingListRejectsHostileLocations(t *testing.T) {
+	var hits atomic.Int32
+	r := newRouter(t, &hits)
+
+	for _, location := range hostileLocations {
+		body, _ := json.Marshal(map[string]any{
+			"id":          1,
+			"description": "papers",
+			"items":       map[string]string{"a": location},
+		})
+		w, p := send(r, http.MethodPost, "/publists", string(body), nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, location)
+		assert.Contains(t, p.Detail, "location must look like /pubs/<id>", location)
+	}
+	//none of them made it to the publications API, or into redis
+	assert.Zero(t, hits.Load())
+	w, _ := send(r, http.MethodGet, "/publists/1", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = send(r, http.MethodPost, "/publists", `{"id": 1, "description": "papers", "items": {"a": "/pubs/1"}}`, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, int32(1), hits.Load())
+}
+
+func TestForgedUserIsRejected(t *testing.T) {
+	var hits atomic.Int32
+	r := newRouter(t, &hits)
+	alice := map[string]string{"X-User": "alice", api.GatewayTokenHeader: gatewayToken}
+
+	w, _ := send(r, http.MethodPost, "/publists", `{"id": 1, "description": "papers"}`, alice)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"owner":"alice"`)
+
+	//a caller that goes around the gateway can set the header, but not
+	//the token
+	forged := map[string]map[string]string{
+		"no token":    {"X-User": "alice"},
+		"wrong token": {"X-User": "alice", api.GatewayTokenHeader: "guess"},
+		"empty token": {"X-User": "alice", api.GatewayTokenHeader: ""},
+	}
+	for name, headers := range forged {
+		w, p := send(r, http.MethodGet, "/publists/1", "", headers)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+		assert.Equal(t, "The X-User header is only accepted from the gateway", p.Detail, name)
+
+		w, _ = send(r, http.MethodPost, "/publists", `{"id": 2, "description": "mine now"}`, headers)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+	}
+
+	//without the header the caller is nobody, and alice's list is private
+	w, _ = send(r, http.MethodGet, "/publists/1", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w, _ = send(r, http.MethodGet, "/publists/1", "", alice)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
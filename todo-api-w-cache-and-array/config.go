@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+
+	"architectingsoftware.com/httpkit/config"
+)
+
+// Config is every setting of the todo API, see the config package in
+// httpkit for how the defaults, a file, the environment and the flags are
+// layered
+type Config struct {
+	Server    config.Server    `yaml:"server" toml:"server"`
+	Redis     config.Cache     `yaml:"redis" toml:"redis"`
+	CORS      config.CORS      `yaml:"cors" toml:"cors"`
+	RateLimit config.RateLimit `yaml:"rateLimit" toml:"rateLimit"`
+	Log       LogConfig        `yaml:"log" toml:"log"`
+}
+
+// LogConfig only has the level, the todo API logs with gin's logger
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// DefaultConfig is good for running the API on a laptop next to a redis
+// container
+func DefaultConfig() *Config {
+	return &Config{
+		Server:    config.DefaultServer(1080),
+		Redis:     config.Cache{Addr: "0.0.0.0:6379"},
+		CORS:      config.DefaultCORS(),
+		RateLimit: config.DefaultRateLimit(),
+		Log:       LogConfig{Level: "info"},
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+	c.Redis.BindFlags(fs, "redis", "redis")
+	c.CORS.BindFlags(fs)
+	c.RateLimit.BindFlags(fs)
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "debug, info, warn or error")
+}
+
+// Settings keeps REDIS_URL from before the config package so the docker
+// files still work
+func (c *Config) Settings() []config.Setting {
+	settings := config.ServerSettings("TODO_")
+	settings = append(settings, config.CacheSettings("redis", "redis", "REDIS_")...)
+	settings = append(settings, config.CORSSettings("TODO_")...)
+	settings = append(settings, config.RateLimitSettings("TODO_")...)
+	return append(settings, config.Setting{Flag: "log-level", Env: "TODO_LOG_LEVEL"})
+}
+
+func (c *Config) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	c.Redis.Check(&p)
+	c.CORS.Check(&p)
+	c.RateLimit.Check(&p)
+	p.Check(config.OneOf(c.Log.Level, "debug", "info", "warn", "error"), "log level %q must be debug, info, warn or error", c.Log.Level)
+	return p.Err()
+}
+
+// String is the config as YAML without the redis password, it is logged
+// when the API starts
+func (c *Config) String() string {
+	r := *c
+	r.Redis = r.Redis.Redacted()
+	return config.String(r)
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
@@ -31,6 +30,14 @@ const (
 	RedisKeyPrefix       = "todo:"
 )
 
+// The errors below are wrapped with the id of the item, check for them with
+// errors.Is.  The api package turns them into the right HTTP status, any
+// other error is a problem with redis
+var (
+	ErrNotFound = errors.New("item does not exist")
+	ErrConflict = errors.New("item already exists")
+)
+
 type cache struct {
 	cacheClient *redis.Client
 	jsonHelper  *rejson.Handler
@@ -48,28 +55,28 @@ type ToDo struct {
 
 // New is a constructor function that returns a pointer to a new
 // ToDo struct.  If this is called it uses the default Redis URL
-// with the companion constructor NewWithCacheInstance.
+// with the companion constructor NewWithCacheInstance.  The API gets its
+// redis settings from the config package and uses NewWithOptions
 func New() (*ToDo, error) {
-	//We will use an override if the REDIS_URL is provided as an environment
-	//variable, which is the preferred way to wire up a docker container
-	redisUrl := os.Getenv("REDIS_URL")
-	//This handles the default condition
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-	return NewWithCacheInstance(redisUrl)
+	return NewWithCacheInstance(RedisDefaultLocation)
 }
 
 // NewWithCacheInstance is a constructor function that returns a pointer to a new
 // ToDo struct.  It accepts a string that represents the location of the redis
 // cache.
 func NewWithCacheInstance(location string) (*ToDo, error) {
-
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
+	return NewWithOptions(&redis.Options{
 		Addr: location,
 	})
+}
+
+// NewWithOptions is a constructor function that returns a pointer to a new
+// ToDo struct.  The options say where redis is and how to log in to it,
+// for example the password, database number and TLS settings
+func NewWithOptions(opts *redis.Options) (*ToDo, error) {
+
+	//Connect to redis
+	client := redis.NewClient(opts)
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -106,7 +113,7 @@ func NewWithCacheInstance(location string) (*ToDo, error) {
 // REDIS HELPERS
 //------------------------------------------------------------
 
-// We will use this later, you can ignore for now
+// JSONGet returns a redis nil error when the key does not exist
 func isRedisNilError(err error) bool {
 	return errors.Is(err, redis.Nil) || err.Error() == RedisNilError
 }
@@ -126,6 +133,9 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 	//json structure
 	itemObject, err := t.jsonHelper.JSONGet(key, ".")
 	if err != nil {
+		if isRedisNilError(err) {
+			return ErrNotFound
+		}
 		return err
 	}
 
@@ -141,6 +151,11 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 	return nil
 }
 
+// Close closes the redis client
+func (t *ToDo) Close() error {
+	return t.cacheClient.Close()
+}
+
 //------------------------------------------------------------
 // THESE ARE THE PUBLIC FUNCTIONS THAT SUPPORT OUR TODO APP
 //------------------------------------------------------------
@@ -164,8 +179,12 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	//it does not exist, if it does, return an error
 	redisKey := redisKeyFromId(item.Id)
 	var existingItem ToDoItem
-	if err := t.getItemFromRedis(redisKey, &existingItem); err == nil {
-		return errors.New("item already exists")
+	err := t.getItemFromRedis(redisKey, &existingItem)
+	if err == nil {
+		return fmt.Errorf("todo %d: %w", item.Id, ErrConflict)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
 	}
 
 	//Add item to database with JSON Set
@@ -198,7 +217,7 @@ func (t *ToDo) DeleteItem(id int) error {
 		return err
 	}
 	if numDeleted == 0 {
-		return errors.New("attempted to delete non-existent item")
+		return fmt.Errorf("todo %d: %w", id, ErrNotFound)
 	}
 
 	return nil
@@ -210,6 +229,10 @@ func (t *ToDo) DeleteAll() error {
 
 	pattern := RedisKeyPrefix + "*"
 	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
+	//Nothing to delete, DEL with no keys is an error in redis
+	if len(ks) == 0 {
+		return nil
+	}
 	//Note delete can take a collection of keys.  In go we can
 	//expand a slice into individual arguments by using the ...
 	//operator
@@ -245,7 +268,7 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	redisKey := redisKeyFromId(item.Id)
 	var existingItem ToDoItem
 	if err := t.getItemFromRedis(redisKey, &existingItem); err != nil {
-		return errors.New("item does not exist")
+		return fmt.Errorf("todo %d: %w", item.Id, err)
 	}
 
 	//Add item to database with JSON Set.  Note there is no update
@@ -281,7 +304,7 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	pattern := redisKeyFromId(id)
 	err := t.getItemFromRedis(pattern, &item)
 	if err != nil {
-		return ToDoItem{}, err
+		return ToDoItem{}, fmt.Errorf("todo %d: %w", id, err)
 	}
 
 	return item, nil
@@ -327,13 +350,19 @@ func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 
 	//Now that we have the DB loaded, lets crate a slice
 	var toDoList []ToDoItem
-	var toDoItem ToDoItem
 
 	//Lets query redis for all of the items
 	pattern := RedisKeyPrefix + "*"
-	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
+	ks, err := t.cacheClient.Keys(t.context, pattern).Result()
+	if err != nil {
+		return nil, err
+	}
 	for _, key := range ks {
+		var toDoItem ToDoItem
 		err := t.getItemFromRedis(key, &toDoItem)
+		if errors.Is(err, ErrNotFound) {
+			continue //deleted since we got the keys
+		}
 		if err != nil {
 			return nil, err
 		}
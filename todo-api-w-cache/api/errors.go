@@ -0,0 +1,31 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"architectingsoftware.com/httpkit"
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+)
+
+// statusForError picks the HTTP status for an error from the database.
+// This is the only place that knows how those errors map to statuses,
+// handlers pass their errors to abortWithError
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// abortWithError sends the problem for a database error.  httpkit logs
+// the errors we did not expect, and their details are not sent since they
+// are about the database and not about the request
+func abortWithError(c *gin.Context, err error) {
+	httpkit.AbortWithErrorStatus(c, statusForError(err), err)
+}
@@ -1,65 +1,69 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/httpkit/config"
 	"drexel.edu/todo/api"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// Global variables to hold the command line flags to drive the todo CLI
-// application
-var (
-	hostFlag string
-	portFlag uint
-)
+// main is the entry point for our todo API application.  It loads the
+// config, see config.go for the settings, and then starts the API
+func main() {
+	cfg := DefaultConfig()
+	if err := config.Load(cfg, os.Args[1:], "TODO_CONFIG"); err != nil {
+		fmt.Println("Error in the config:", err)
+		os.Exit(1)
+	}
+	//The passwords are starred out so this is safe to log
+	log.Printf("Config:\n%s", cfg)
 
-// processCmdLineFlags parses the command line flags for our CLI
-//
-// TODO: This function uses the flag package to parse the command line
-//		 flags.  The flag package is not very flexible and can lead to
-//		 some confusing code.
-
-//			 REQUIRED:     Study the code below, and make sure you understand
-//						   how it works.  Go online and readup on how the
-//						   flag package works.  Then, write a nice comment
-//				  		   block to document this function that highights that
-//						   you understand how it works.
-//
-//			 EXTRA CREDIT: The best CLI and command line processor for
-//						   go is called Cobra.  Refactor this function to
-//						   use it.  See github.com/spf13/cobra for information
-//						   on how to use it.
-//
-//	 YOUR ANSWER: <GOES HERE>
-func processCmdLineFlags() {
-
-	//Note some networking lingo, some frameworks start the server on localhost
-	//this is a local-only interface and is fine for testing but its not accessible
-	//from other machines.  To make the server accessible from other machines, we
-	//need to listen on an interface, that could be an IP address, but modern
-	//cloud servers may have multiple network interfaces for scale.  With TCP/IP
-	//the address 0.0.0.0 instructs the network stack to listen on all interfaces
-	//We set this up as a flag so that we can overwrite it on the command line if
-	//needed
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
-
-	flag.Parse()
-}
+	if cfg.Log.Level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	//gin.Default() with a recovery that sends a problem like every other
+	//error, a panic in a handler such as /crash is a 500 and the API
+	//keeps running
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		httpkit.AbortWithStatus(c, http.StatusInternalServerError, httpkit.InternalErrorDetail)
+	}))
+	r.Use(cors.New(httpkit.CORSConfig(cfg.CORS.Origins)))
+	r.NoRoute(httpkit.NoRoute)
 
-// main is the entry point for our todo API application.  It processes
-// the command line flags and then uses the db package to perform the
-// requested operation
-func main() {
-	processCmdLineFlags()
-	r := gin.Default()
-	r.Use(cors.Default())
+	redisOpts, err := cfg.Redis.RedisOptions()
+	if err != nil {
+		fmt.Println("Error setting up redis:", err)
+		os.Exit(1)
+	}
+
+	//every IP address gets a bucket of requests for each route, see
+	//httpkit.  It runs before the handlers so every request is counted
+	rateStore, err := httpkit.NewRateStore(cfg.RateLimit.Store, redisOpts)
+	if err != nil {
+		fmt.Println("Error setting up rate limiting:", err)
+		os.Exit(1)
+	}
+	var limiter *httpkit.RateLimiter
+	if rateStore != nil {
+		limiter = httpkit.NewRateLimiter(rateStore, httpkit.PerMinute(cfg.RateLimit.Default))
+		r.Use(limiter.Handler(httpkit.Limit{}))
+	}
 
-	apiHandler, err := api.New()
+	apiHandler, err := api.New(redisOpts)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -81,6 +85,56 @@ func main() {
 	v2 := r.Group("/v2")
 	v2.GET("/todo", apiHandler.ListSelectTodos)
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+	if err := serve(srv, cfg.Server.ShutdownGrace.Std()); err != nil {
+		fmt.Println(err)
+	}
+
+	//The server has stopped so nothing is using redis anymore
+	if limiter != nil {
+		if err := limiter.Close(); err != nil {
+			log.Println("Error closing rate limiter: ", err)
+		}
+	}
+	if err := apiHandler.Close(); err != nil {
+		log.Println("Error closing redis: ", err)
+	}
+	log.Println("Stopped")
+}
+
+// serve runs the server until it gets SIGINT or SIGTERM, which is what
+// docker sends to stop a container.  It then stops taking new connections
+// and gives the requests that are running the grace period to finish
+func serve(srv *http.Server, grace time.Duration) error {
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		//the server could not start, for example the port is in use
+		return err
+	case <-stopCtx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for requests to finish", grace)
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("requests did not finish in time: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
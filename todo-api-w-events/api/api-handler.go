@@ -1,10 +1,13 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 
+	"architectingsoftware.com/httpkit"
 	"drexel.edu/todo-events/db"
 	"drexel.edu/todo-events/events"
 	"github.com/gin-gonic/gin"
@@ -17,9 +20,11 @@ type ToDoAPI struct {
 	eventHandler *events.ToDoEventManager
 }
 
-func New() (*ToDoAPI, error) {
+// New sets up the API, the todos are loaded from and flushed to dbFile,
+// or kept in memory only if it is ""
+func New(dbFile string) (*ToDoAPI, error) {
 
-	dbHandler, err := db.New()
+	dbHandler, err := db.NewWithFile(dbFile)
 	if err != nil {
 		return nil, err
 	}
@@ -44,8 +49,25 @@ func (td *ToDoAPI) StopEventListener() {
 	td.eventHandler.Stop()
 }
 
+// Close stops the event listener, waiting until ctx is done for the event
+// that is being processed, and then flushes the db.  It is called when the
+// API shuts down, after the server has stopped so no more events are
+// coming.  The db is flushed even if the event listener did not stop in
+// time
+func (td *ToDoAPI) Close(ctx context.Context) error {
+	var eventErr error
+	if td.eventHandler != nil {
+		eventErr = td.eventHandler.Shutdown(ctx)
+	}
+	if err := td.db.Close(); err != nil {
+		return fmt.Errorf("flushing the db: %w", err)
+	}
+	return eventErr
+}
+
+// Notify sends an event to the event listener, if there is one
 func (td *ToDoAPI) Notify(event *events.ToDoEvent) {
-	if td.eventHandler == nil {
+	if td.eventHandler != nil {
 		td.eventHandler.Notify(event)
 	}
 }
@@ -58,8 +80,10 @@ func (td *ToDoAPI) Notify(event *events.ToDoEvent) {
 //   3) How to return JSON and a correctly formed HTTP status code
 //	  for example, 200 for OK, 404 for not found, etc.  This is done
 //	  using the c.JSON() function
-//   4) How to return an error code and abort the request.  This is
-//	  done using the c.AbortWithStatus() function
+//   4) How to return an error code and abort the request.  Errors are
+//	  sent as RFC 7807 problem details using httpkit.AbortWithStatus(), and
+//	  database errors go through abortWithError() which picks the
+//	  status for them, see errors.go
 
 // implementation for GET /todo
 // returns all todos
@@ -67,8 +91,7 @@ func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 
 	todoList, err := td.db.GetAllItems()
 	if err != nil {
-		log.Println("Error Getting All Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 	//Note that the database returns a nil slice if there are no items
@@ -80,7 +103,7 @@ func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 	}
 
 	evnt := events.NewEvent(events.ToDoQueryEvent, "todoList", todoList)
-	td.eventHandler.Notify(evnt)
+	td.Notify(evnt)
 
 	c.JSON(http.StatusOK, todoList)
 }
@@ -95,8 +118,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 	//lets first load the data
 	todoList, err := td.db.GetAllItems()
 	if err != nil {
-		log.Println("Error Getting Database Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 	//If the database is empty, make an empty slice so that the
@@ -120,8 +142,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 
 	done, err := strconv.ParseBool(doneS)
 	if err != nil {
-		log.Println("Error converting done to bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "done must be true or false")
 		return
 	}
 
@@ -156,8 +177,7 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	idS := c.Param("id")
 	id64, err := strconv.ParseInt(idS, 10, 32)
 	if err != nil {
-		log.Println("Error converting id to int64: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The todo id must be a number")
 		return
 	}
 
@@ -165,13 +185,12 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	//convert it to an int before we can use it.
 	todoItem, err := td.db.GetItem(int(id64))
 	if err != nil {
-		log.Println("Item not found: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 
 	evnt := events.NewEvent(events.ToDoQueryEvent, "todoItem", todoItem)
-	td.eventHandler.Notify(evnt)
+	td.Notify(evnt)
 	//Git will automatically convert the struct to JSON
 	//and set the content-type header to application/json
 	c.JSON(http.StatusOK, todoItem)
@@ -194,18 +213,16 @@ func (td *ToDoAPI) AddToDo(c *gin.Context) {
 	//if the body is not JSON or if the JSON does not match
 	//the struct we are binding to.
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The body must be a todo item in JSON")
 		return
 	}
 
 	if err := td.db.AddItem(todoItem); err != nil {
-		log.Println("Error adding item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 	evnt := events.NewEvent(events.ToDoAddEvent, "todoItem", todoItem)
-	td.eventHandler.Notify(evnt)
+	td.Notify(evnt)
 
 	c.JSON(http.StatusOK, todoItem)
 }
@@ -215,19 +232,17 @@ func (td *ToDoAPI) AddToDo(c *gin.Context) {
 func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 	var todoItem db.ToDoItem
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The body must be a todo item in JSON")
 		return
 	}
 
 	if err := td.db.UpdateItem(todoItem); err != nil {
-		log.Println("Error updating item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
 	evnt := events.NewEvent(events.ToDoUpdateEvent, "todoItem", todoItem)
-	td.eventHandler.Notify(evnt)
+	td.Notify(evnt)
 	c.JSON(http.StatusOK, todoItem)
 }
 
@@ -235,16 +250,19 @@ func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 // deletes a todo
 func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 	idS := c.Param("id")
-	id64, _ := strconv.ParseInt(idS, 10, 32)
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The todo id must be a number")
+		return
+	}
 
 	if err := td.db.DeleteItem(int(id64)); err != nil {
-		log.Println("Error deleting item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
 	evnt := events.NewEvent(events.ToDoDeleteEvent, "id", id64)
-	td.eventHandler.Notify(evnt)
+	td.Notify(evnt)
 
 	c.Status(http.StatusOK)
 }
@@ -254,13 +272,12 @@ func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 func (td *ToDoAPI) DeleteAllToDo(c *gin.Context) {
 
 	if err := td.db.DeleteAll(); err != nil {
-		log.Println("Error deleting all items: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
 	evnt := events.NewEvent(events.ToDoDeleteEvent, "id", "all")
-	td.eventHandler.Notify(evnt)
+	td.Notify(evnt)
 
 	c.Status(http.StatusOK)
 }
@@ -300,8 +317,7 @@ func (td *ToDoAPI) EventEnabler(c *gin.Context) {
 	enable := c.Param("enableFlag")
 	eFlag, err := strconv.ParseBool(enable)
 	if err != nil {
-		log.Println("Error converting enable flag, must be bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "enableFlag must be true or false")
 		return
 	}
 
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The algorithms that can be used to sign tokens.  HS256 uses a shared
+// secret, RS256 uses an RSA key pair so the API only needs the public key
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// userContextKey and rolesContextKey are where the middleware stores the
+// caller in the gin context
+const (
+	userContextKey  = "todo-user"
+	rolesContextKey = "todo-roles"
+)
+
+// Authenticator checks the bearer tokens sent to the API.  Tokens are JWTs,
+// the "sub" claim is the caller and the "roles" claim says what they are
+// allowed to do, see rbac.go.  Tokens must also have an "exp" claim so a
+// leaked token does not work forever.  The todo API in
+// todo-container-compose checks tokens the same way, its mktoken command
+// makes tokens for this API too
+type Authenticator struct {
+	alg string
+	key interface{} //[]byte for HS256, *rsa.PublicKey for RS256
+}
+
+// NewAuthenticator loads the key used to check tokens.  For HS256 the
+// file holds the shared secret, for RS256 it holds the PEM encoded public
+// key
+func NewAuthenticator(alg string, keyFile string) (*Authenticator, error) {
+	if keyFile == "" {
+		return nil, errors.New("a key file is required to check tokens")
+	}
+	keyBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{alg: strings.ToUpper(alg)}
+	switch a.alg {
+	case AlgHS256:
+		//editors like to leave a newline at the end of the file, that
+		//should not be part of the secret
+		secret := []byte(strings.TrimSpace(string(keyBytes)))
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secrets must be at least 32 bytes")
+		}
+		a.key = secret
+	case AlgRS256:
+		var pubKey *rsa.PublicKey
+		if pubKey, err = jwt.ParseRSAPublicKeyFromPEM(keyBytes); err != nil {
+			return nil, err
+		}
+		a.key = pubKey
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use %s or %s", alg, AlgHS256, AlgRS256)
+	}
+	return a, nil
+}
+
+// Middleware returns gin middleware that rejects requests without a valid
+// token with a 401.  For valid tokens the caller is saved in the context
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := a.claimsFromRequest(c.Request)
+		if err != nil {
+			log.Println("Rejected token: ", err)
+			c.Header("WWW-Authenticate", `Bearer realm="todo"`)
+			httpkit.AbortWithStatus(c, http.StatusUnauthorized, "A valid bearer token is required")
+			return
+		}
+		c.Set(userContextKey, claims.Subject)
+		c.Set(rolesContextKey, claims.Roles)
+		c.Next()
+	}
+}
+
+// tokenClaims are the claims we use from a token
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+func (a *Authenticator) claimsFromRequest(req *http.Request) (*tokenClaims, error) {
+	scheme, tokenString, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+		return nil, errors.New("no bearer token")
+	}
+
+	//Only accept the configured algorithm, otherwise a token could pick
+	//an algorithm that we never intended to trust
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(tokenString), claims, func(*jwt.Token) (interface{}, error) {
+		return a.key, nil
+	}, jwt.WithValidMethods([]string{a.alg}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("the token has no subject")
+	}
+	return claims, nil
+}
+
+// CurrentUser returns the caller that was authenticated by the middleware
+func CurrentUser(c *gin.Context) string {
+	return c.GetString(userContextKey)
+}
+
+// CurrentRoles returns the roles from the caller's token
+func CurrentRoles(c *gin.Context) []string {
+	return c.GetStringSlice(rolesContextKey)
+}
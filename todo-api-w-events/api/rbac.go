@@ -0,0 +1,88 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/gin-gonic/gin"
+)
+
+// Role is what a caller is allowed to do.  Roles are ordered, each role
+// can do everything the roles before it can do.  Roles come from the
+// "roles" claim of the token, a token without roles is a viewer
+type Role string
+
+const (
+	RolePublic Role = ""       //no token needed
+	RoleViewer Role = "viewer" //read todos
+	RoleEditor Role = "editor" //add, change and delete single todos
+	RoleAdmin  Role = "admin"  //delete every todo, turn events on and off and the crash route
+)
+
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// hasRole reports if any of the roles is at least the needed role, roles
+// we don't know about are ignored
+func hasRole(roles []string, need Role) bool {
+	if len(roles) == 0 {
+		roles = []string{string(RoleViewer)}
+	}
+	for _, r := range roles {
+		if rank, ok := roleRank[Role(strings.ToLower(r))]; ok && rank >= roleRank[need] {
+			return true
+		}
+	}
+	return false
+}
+
+// auditLog records requests that were denied.  It writes to stderr with
+// its own prefix so it is easy to pick out of the logs
+var auditLog = log.New(os.Stderr, "AUDIT ", log.LstdFlags|log.LUTC)
+
+// Route is one row of the policy table, the route and the role needed to
+// use it
+type Route struct {
+	Method  string
+	Path    string
+	Role    Role
+	Handler gin.HandlerFunc
+}
+
+// RequireRole returns gin middleware that sends a 403 if the caller does
+// not have the role.  It must run after Middleware
+func (a *Authenticator) RequireRole(need Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hasRole(CurrentRoles(c), need) {
+			c.Next()
+			return
+		}
+		auditLog.Printf("denied user=%q roles=%q need=%s method=%s path=%s ip=%s",
+			CurrentUser(c), CurrentRoles(c), need, c.Request.Method, c.Request.URL.Path, c.ClientIP())
+		httpkit.AbortWithStatus(c, http.StatusForbidden, "This needs the "+string(need)+" role")
+	}
+}
+
+// Register adds all of the routes in the policy table.  Routes that need
+// a role check the token and then the role, public routes don't.  a can
+// be nil when no key was configured, then only the public routes are
+// added
+func (a *Authenticator) Register(r gin.IRouter, routes []Route) {
+	for _, rt := range routes {
+		if rt.Role == RolePublic {
+			r.Handle(rt.Method, rt.Path, rt.Handler)
+			continue
+		}
+		if a == nil {
+			log.Printf("No token key, %s %s is not available", rt.Method, rt.Path)
+			continue
+		}
+		r.Handle(rt.Method, rt.Path, a.Middleware(), a.RequireRole(rt.Role), rt.Handler)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+// mktoken makes a bearer token for trying out the todo API.  In a real
+// system tokens come from an identity provider, this just signs one with
+// the same key the API checks them with, make key makes the development
+// key.  For example:
+//
+//	go run ./cmd/mktoken -key keys/dev-hs256.key -sub alice -roles admin
+//	go run ./cmd/mktoken -alg RS256 -key private.pem -sub bob -roles admin -ttl 1h
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	algFlag   string
+	keyFlag   string
+	subFlag   string
+	rolesFlag string
+	ttlFlag   time.Duration
+)
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+}
+
+func main() {
+	flag.StringVar(&algFlag, "alg", "HS256", "HS256 or RS256")
+	flag.StringVar(&keyFlag, "key", "", "File with the HS256 secret or the RS256 private key (PEM)")
+	flag.StringVar(&subFlag, "sub", "", "The user the token is for")
+	flag.StringVar(&rolesFlag, "roles", "editor", "Comma separated roles: viewer, editor or admin")
+	flag.DurationVar(&ttlFlag, "ttl", 24*time.Hour, "How long the token is good for")
+	flag.Parse()
+
+	if keyFlag == "" || subFlag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	keyBytes, err := os.ReadFile(keyFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading key:", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subFlag,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttlFlag)),
+		},
+	}
+	for _, r := range strings.Split(rolesFlag, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			claims.Roles = append(claims.Roles, r)
+		}
+	}
+
+	var signed string
+	switch strings.ToUpper(algFlag) {
+	case "HS256":
+		secret := []byte(strings.TrimSpace(string(keyBytes)))
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	case "RS256":
+		privKey, perr := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+		if perr != nil {
+			fmt.Fprintln(os.Stderr, "Error reading private key:", perr)
+			os.Exit(1)
+		}
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privKey)
+	default:
+		err = fmt.Errorf("unsupported algorithm %q", algFlag)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error signing token:", err)
+		os.Exit(1)
+	}
+	fmt.Println(signed)
+}
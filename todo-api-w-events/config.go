@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"architectingsoftware.com/httpkit/config"
+)
+
+// Config is every setting of the todo API, see the config package in
+// httpkit for how the defaults, a file, the environment and the flags are
+// layered
+type Config struct {
+	Server    ServerConfig     `yaml:"server" toml:"server"`
+	Auth      AuthConfig       `yaml:"auth" toml:"auth"`
+	DB        DBConfig         `yaml:"db" toml:"db"`
+	CORS      config.CORS      `yaml:"cors" toml:"cors"`
+	RateLimit config.RateLimit `yaml:"rateLimit" toml:"rateLimit"`
+	Log       LogConfig        `yaml:"log" toml:"log"`
+}
+
+// ServerConfig is the shared server settings plus the demo routes
+type ServerConfig struct {
+	config.Server `yaml:",inline"`
+	DemoRoutes    bool `yaml:"demoRoutes" toml:"demoRoutes"`
+}
+
+// AuthConfig is how the tokens for the admin routes are signed, the admin
+// routes are not added without a key file
+type AuthConfig struct {
+	Alg     string `yaml:"alg" toml:"alg"`
+	KeyFile string `yaml:"keyFile" toml:"keyFile"`
+}
+
+type DBConfig struct {
+	File string `yaml:"file" toml:"file"` //"" keeps the todos in memory only
+}
+
+// LogConfig only has the level, the todo API logs with gin's logger
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// DefaultConfig is good for running the API on a laptop
+func DefaultConfig() *Config {
+	return &Config{
+		Server:    ServerConfig{Server: config.DefaultServer(1080)},
+		Auth:      AuthConfig{Alg: "HS256"},
+		CORS:      config.DefaultCORS(),
+		RateLimit: config.DefaultRateLimit(),
+		Log:       LogConfig{Level: "info"},
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+
+	//The admin routes need a bearer token signed with this key, without
+	//a key they are not added.  /crash and /event are only for demos, they
+	//are not added unless they are turned on as well
+	fs.StringVar(&c.Auth.Alg, "auth-alg", c.Auth.Alg, "HS256 or RS256")
+	fs.StringVar(&c.Auth.KeyFile, "auth-key", c.Auth.KeyFile, "HS256 secret or RS256 public key file for the admin routes")
+	fs.BoolVar(&c.Server.DemoRoutes, "demo-routes", c.Server.DemoRoutes, "Add the /crash and /event routes")
+
+	fs.StringVar(&c.DB.File, "db-file", c.DB.File, "JSON file the todos are loaded from and flushed to when stopping.  Empty keeps them in memory only")
+
+	c.CORS.BindFlags(fs)
+	c.RateLimit.BindFlags(fs)
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "debug, info, warn or error")
+}
+
+func (c *Config) Settings() []config.Setting {
+	settings := config.ServerSettings("TODO_")
+	settings = append(settings,
+		config.Setting{Flag: "demo-routes", Env: "TODO_ENABLE_DEMO_ROUTES"},
+		config.Setting{Flag: "auth-alg", Env: "TODO_AUTH_ALG"},
+		config.Setting{Flag: "auth-key", Env: "TODO_AUTH_KEY_FILE"},
+		config.Setting{Flag: "db-file", Env: "TODO_DB_FILE"},
+	)
+	settings = append(settings, config.CORSSettings("TODO_")...)
+	settings = append(settings, config.RateLimitSettings("TODO_")...)
+	return append(settings, config.Setting{Flag: "log-level", Env: "TODO_LOG_LEVEL"})
+}
+
+func (c *Config) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	alg := strings.ToUpper(c.Auth.Alg)
+	p.Check(alg == "HS256" || alg == "RS256", "auth alg %q must be HS256 or RS256", c.Auth.Alg)
+	c.CORS.Check(&p)
+	c.RateLimit.Check(&p)
+	//there is no redis to share the limits through, every instance keeps
+	//its own
+	p.Check(c.RateLimit.Store != "redis", "rate limit store redis needs a redis, use memory or off")
+	p.Check(config.OneOf(c.Log.Level, "debug", "info", "warn", "error"), "log level %q must be debug, info, warn or error", c.Log.Level)
+	return p.Err()
+}
+
+// String is the config as YAML, it is logged when the API starts
+func (c *Config) String() string {
+	return config.String(c)
+}
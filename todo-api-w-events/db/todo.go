@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"sort"
 )
 
 // ToDoItem is the struct that represents a single ToDo item
@@ -13,6 +15,13 @@ type ToDoItem struct {
 	IsDone bool   `json:"done"`
 }
 
+// The errors below are wrapped with the id of the item, check for them with
+// errors.Is.  The api package turns them into the right HTTP status
+var (
+	ErrNotFound = errors.New("item does not exist")
+	ErrConflict = errors.New("item already exists")
+)
+
 // DbMap is a type alias for a map of ToDoItems.  The key
 // will be the ToDoItem.Id and the value will be the ToDoItem
 type DbMap map[int]ToDoItem
@@ -25,6 +34,9 @@ type DbMap map[int]ToDoItem
 // map
 type ToDo struct {
 	toDoMap DbMap
+	//the file the items are loaded from and flushed to, "" keeps them
+	//in memory only
+	dbFileName string
 	//more things would be included in a real implementation
 }
 
@@ -46,6 +58,57 @@ func New() (*ToDo, error) {
 	return toDo, nil
 }
 
+// NewWithFile is like New but it loads the items from dbFile, if it
+// exists, and Close flushes them back to it.  The items are only written
+// when the API shuts down, so a crash loses the changes since it started
+func NewWithFile(dbFile string) (*ToDo, error) {
+	toDo, err := New()
+	if err != nil || dbFile == "" {
+		return toDo, err
+	}
+	toDo.dbFileName = dbFile
+
+	data, err := os.ReadFile(dbFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return toDo, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []ToDoItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dbFile, err)
+	}
+	for _, item := range items {
+		toDo.toDoMap[item.Id] = item
+	}
+	return toDo, nil
+}
+
+// Close flushes the items to the file they were loaded from, it does
+// nothing if there is no file.  The file is written to a temp file first
+// and then renamed, so a failed flush does not leave half a file
+func (t *ToDo) Close() error {
+	if t.dbFileName == "" {
+		return nil
+	}
+	items, _ := t.GetAllItems()
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	if items == nil {
+		items = []ToDoItem{}
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := t.dbFileName + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.dbFileName)
+}
+
 //------------------------------------------------------------
 // THESE ARE THE PUBLIC FUNCTIONS THAT SUPPORT OUR TODO APP
 //------------------------------------------------------------
@@ -69,7 +132,7 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	//it does not exist, if it does, return an error
 	_, ok := t.toDoMap[item.Id]
 	if ok {
-		return errors.New("item already exists")
+		return fmt.Errorf("todo %d: %w", item.Id, ErrConflict)
 	}
 
 	//Now that we know the item doesn't exist, lets add it to our map
@@ -97,6 +160,9 @@ func (t *ToDo) DeleteItem(id int) error {
 	// we should if item exists before trying to delete it
 	// this is a good practice, return an error if the
 	// item does not exist
+	if _, ok := t.toDoMap[id]; !ok {
+		return fmt.Errorf("todo %d: %w", id, ErrNotFound)
+	}
 
 	//Now lets use the built-in go delete() function to remove
 	//the item from our map
@@ -136,7 +202,7 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	// item does not exist
 	_, ok := t.toDoMap[item.Id]
 	if !ok {
-		return errors.New("item does not exist")
+		return fmt.Errorf("todo %d: %w", item.Id, ErrNotFound)
 	}
 
 	//Now that we know the item exists, lets update it
@@ -166,7 +232,7 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	// item does not exist
 	item, ok := t.toDoMap[id]
 	if !ok {
-		return ToDoItem{}, errors.New("item does not exist")
+		return ToDoItem{}, fmt.Errorf("todo %d: %w", id, ErrNotFound)
 	}
 
 	return item, nil
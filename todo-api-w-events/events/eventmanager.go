@@ -11,6 +11,7 @@ type ToDoEventManager struct {
 	cancel   context.CancelFunc
 	queue    chan *ToDoEvent
 	isActive bool
+	done     chan struct{} //closed when the event loop returns
 }
 
 func NewToDoEventManager() *ToDoEventManager {
@@ -26,11 +27,13 @@ func (em *ToDoEventManager) Start() {
 	if !em.isActive {
 		em.ctx, em.cancel = context.WithCancel(context.Background())
 		em.isActive = true
+		em.done = make(chan struct{})
 		go em.eventLoop()
 	}
 }
 
 func (em *ToDoEventManager) eventLoop() {
+	defer close(em.done)
 	log.Println("Starting Event Loop...")
 	for {
 		select {
@@ -51,6 +54,22 @@ func (em *ToDoEventManager) Stop() {
 	}
 }
 
+// Shutdown stops the event manager and waits for the event that is being
+// processed to finish, or for ctx to be done
+func (em *ToDoEventManager) Shutdown(ctx context.Context) error {
+	if !em.isActive {
+		return nil
+	}
+	done := em.done
+	em.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (em *ToDoEventManager) Notify(event *ToDoEvent) {
 	if em.isActive {
 		em.queue <- event
@@ -1,10 +1,19 @@
 module drexel.edu/todo-events
 
-go 1.20
+go 1.21
 
 require github.com/gin-gonic/gin v1.9.1
 
+require github.com/golang-jwt/jwt/v5 v5.2.1
+
 require (
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
+)
+
+require (
+	architectingsoftware.com/httpkit v1.0.0
 	github.com/bytedance/sonic v1.9.1 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.2 // indirect
@@ -31,3 +40,5 @@ require (
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace architectingsoftware.com/httpkit => ../httpkit
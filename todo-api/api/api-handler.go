@@ -1,10 +1,10 @@
 package api
 
 import (
-	"log"
 	"net/http"
 	"strconv"
 
+	"architectingsoftware.com/httpkit"
 	"drexel.edu/todo/db"
 	"github.com/gin-gonic/gin"
 )
@@ -32,8 +32,10 @@ func New() (*ToDoAPI, error) {
 //   3) How to return JSON and a correctly formed HTTP status code
 //	  for example, 200 for OK, 404 for not found, etc.  This is done
 //	  using the c.JSON() function
-//   4) How to return an error code and abort the request.  This is
-//	  done using the c.AbortWithStatus() function
+//   4) How to return an error code and abort the request.  Errors are
+//	  sent as RFC 7807 problem details using httpkit.AbortWithStatus(), and
+//	  database errors go through abortWithError() which picks the
+//	  status for them, see errors.go
 
 // implementation for GET /todo
 // returns all todos
@@ -41,8 +43,7 @@ func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 
 	todoList, err := td.db.GetAllItems()
 	if err != nil {
-		log.Println("Error Getting All Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 	//Note that the database returns a nil slice if there are no items
@@ -66,8 +67,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 	//lets first load the data
 	todoList, err := td.db.GetAllItems()
 	if err != nil {
-		log.Println("Error Getting Database Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 	//If the database is empty, make an empty slice so that the
@@ -91,8 +91,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 
 	done, err := strconv.ParseBool(doneS)
 	if err != nil {
-		log.Println("Error converting done to bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "done must be true or false")
 		return
 	}
 
@@ -127,8 +126,7 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	idS := c.Param("id")
 	id64, err := strconv.ParseInt(idS, 10, 32)
 	if err != nil {
-		log.Println("Error converting id to int64: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The todo id must be a number")
 		return
 	}
 
@@ -136,8 +134,7 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	//convert it to an int before we can use it.
 	todoItem, err := td.db.GetItem(int(id64))
 	if err != nil {
-		log.Println("Item not found: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 
@@ -163,14 +160,12 @@ func (td *ToDoAPI) AddToDo(c *gin.Context) {
 	//if the body is not JSON or if the JSON does not match
 	//the struct we are binding to.
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The body must be a todo item in JSON")
 		return
 	}
 
 	if err := td.db.AddItem(todoItem); err != nil {
-		log.Println("Error adding item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
@@ -182,14 +177,12 @@ func (td *ToDoAPI) AddToDo(c *gin.Context) {
 func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 	var todoItem db.ToDoItem
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The body must be a todo item in JSON")
 		return
 	}
 
 	if err := td.db.UpdateItem(todoItem); err != nil {
-		log.Println("Error updating item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
@@ -200,11 +193,14 @@ func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 // deletes a todo
 func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 	idS := c.Param("id")
-	id64, _ := strconv.ParseInt(idS, 10, 32)
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The todo id must be a number")
+		return
+	}
 
 	if err := td.db.DeleteItem(int(id64)); err != nil {
-		log.Println("Error deleting item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
@@ -216,8 +212,7 @@ func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 func (td *ToDoAPI) DeleteAllToDo(c *gin.Context) {
 
 	if err := td.db.DeleteAll(); err != nil {
-		log.Println("Error deleting all items: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		abortWithError(c, err)
 		return
 	}
 
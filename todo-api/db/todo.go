@@ -13,6 +13,13 @@ type ToDoItem struct {
 	IsDone bool   `json:"done"`
 }
 
+// The errors below are wrapped with the id of the item, check for them with
+// errors.Is.  The api package turns them into the right HTTP status
+var (
+	ErrNotFound = errors.New("item does not exist")
+	ErrConflict = errors.New("item already exists")
+)
+
 // DbMap is a type alias for a map of ToDoItems.  The key
 // will be the ToDoItem.Id and the value will be the ToDoItem
 type DbMap map[int]ToDoItem
@@ -69,7 +76,7 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	//it does not exist, if it does, return an error
 	_, ok := t.toDoMap[item.Id]
 	if ok {
-		return errors.New("item already exists")
+		return fmt.Errorf("todo %d: %w", item.Id, ErrConflict)
 	}
 
 	//Now that we know the item doesn't exist, lets add it to our map
@@ -97,6 +104,9 @@ func (t *ToDo) DeleteItem(id int) error {
 	// we should if item exists before trying to delete it
 	// this is a good practice, return an error if the
 	// item does not exist
+	if _, ok := t.toDoMap[id]; !ok {
+		return fmt.Errorf("todo %d: %w", id, ErrNotFound)
+	}
 
 	//Now lets use the built-in go delete() function to remove
 	//the item from our map
@@ -136,7 +146,7 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	// item does not exist
 	_, ok := t.toDoMap[item.Id]
 	if !ok {
-		return errors.New("item does not exist")
+		return fmt.Errorf("todo %d: %w", item.Id, ErrNotFound)
 	}
 
 	//Now that we know the item exists, lets update it
@@ -166,7 +176,7 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	// item does not exist
 	item, ok := t.toDoMap[id]
 	if !ok {
-		return ToDoItem{}, errors.New("item does not exist")
+		return ToDoItem{}, fmt.Errorf("todo %d: %w", id, ErrNotFound)
 	}
 
 	return item, nil
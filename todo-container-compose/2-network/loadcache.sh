@@ -1,5 +1,7 @@
 #!/bin/bash
-curl -d '{ "id": 1, "title": "Learn Go / GoLang", "done": false }' -H "Content-Type: application/json" -X POST http://localhost:1080/todo 
-curl -d '{ "id": 2, "title": "Learn Kubernetes", "done": false}' -H "Content-Type: application/json" -X POST http://localhost:1080/todo 
-curl -d '{"id": 3,"title": "Learn Cloud Native Architecure","done":false}' -H "Content-Type: application/json" -X POST http://localhost:1080/todo
+#the todo API needs a token, make one for the demo user with the dev key
+TOKEN=${TOKEN:-$(cd ../api && make -s key && go run ./cmd/mktoken -key ./keys/dev-hs256.key -sub demo)}
+curl -H "Authorization: Bearer $TOKEN" -d '{ "id": 1, "title": "Learn Go / GoLang", "done": false }' -H "Content-Type: application/json" -X POST http://localhost:1080/todo 
+curl -H "Authorization: Bearer $TOKEN" -d '{ "id": 2, "title": "Learn Kubernetes", "done": false}' -H "Content-Type: application/json" -X POST http://localhost:1080/todo 
+curl -H "Authorization: Bearer $TOKEN" -d '{"id": 3,"title": "Learn Cloud Native Architecure","done":false}' -H "Content-Type: application/json" -X POST http://localhost:1080/todo
 	
\ No newline at end of file
@@ -1,13 +1,14 @@
 package api
 
 import (
-	"log"
 	"net/http"
 	"os"
 	"strconv"
 
+	"architectingsoftware.com/httpkit"
 	"drexel.edu/todo/db"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 // The api package creates and maintains a reference to the data handler
@@ -16,8 +17,8 @@ type ToDoAPI struct {
 	db *db.ToDo
 }
 
-func New() (*ToDoAPI, error) {
-	dbHandler, err := db.New()
+func New(redisOpts *redis.Options) (*ToDoAPI, error) {
+	dbHandler, err := db.NewWithOptions(redisOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +26,11 @@ func New() (*ToDoAPI, error) {
 	return &ToDoAPI{db: dbHandler}, nil
 }
 
+// Close releases the database, it is called when the API shuts down
+func (td *ToDoAPI) Close() error {
+	return td.db.Close()
+}
+
 //Below we implement the API functions.  Some of the framework
 //things you will see include:
 //   1) How to extract a parameter from the URL, for example
@@ -33,17 +39,22 @@ func New() (*ToDoAPI, error) {
 //   3) How to return JSON and a correctly formed HTTP status code
 //	  for example, 200 for OK, 404 for not found, etc.  This is done
 //	  using the c.JSON() function
-//   4) How to return an error code and abort the request.  This is
-//	  done using the c.AbortWithStatus() function
+//   4) How to return an error code and abort the request.  Errors are
+//	  sent as RFC 7807 problem details using httpkit.AbortWithStatus(), and
+//	  database errors go through abortWithError() which picks the
+//	  status for them, see errors.go
+
+//Every /todo handler runs after the Authenticator middleware, so
+//CurrentUser(c) is the caller and the database only works with the
+//items that belong to them
 
 // implementation for GET /todo
-// returns all todos
+// returns all of the caller's todos
 func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 
-	todoList, err := td.db.GetAllItems()
+	todoList, err := td.db.GetAllItems(CurrentUser(c))
 	if err != nil {
-		log.Println("Error Getting All Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 	//Note that the database returns a nil slice if there are no items
@@ -65,10 +76,9 @@ func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 // query parameters, for example /v2/todo?done=true&foo=bar
 func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 	//lets first load the data
-	todoList, err := td.db.GetAllItems()
+	todoList, err := td.db.GetAllItems(CurrentUser(c))
 	if err != nil {
-		log.Println("Error Getting Database Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
 		return
 	}
 	//If the database is empty, make an empty slice so that the
@@ -92,8 +102,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 
 	done, err := strconv.ParseBool(doneS)
 	if err != nil {
-		log.Println("Error converting done to bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "done must be true or false")
 		return
 	}
 
@@ -128,17 +137,24 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	idS := c.Param("id")
 	id64, err := strconv.ParseInt(idS, 10, 32)
 	if err != nil {
-		log.Println("Error converting id to int64: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The todo id must be a number")
 		return
 	}
 
 	//Note that ParseInt always returns an int64, so we have to
 	//convert it to an int before we can use it.
-	todoItem, err := td.db.GetItem(int(id64))
+	todoItem, err := td.db.GetItem(CurrentUser(c), int(id64))
 	if err != nil {
-		log.Println("Item not found: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		abortWithError(c, err)
+		return
+	}
+
+	//The ETag is the version of the todo, the client sends it back
+	//in If-Match when it updates the todo, see etag.go.  A client that
+	//already has this version gets a 304 without the todo
+	setETag(c, todoItem)
+	if c.GetHeader("If-None-Match") == etag(todoItem.Version) {
+		c.Status(http.StatusNotModified)
 		return
 	}
 
@@ -150,50 +166,56 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 // implementation for POST /todo
 // adds a new todo
 func (td *ToDoAPI) AddToDo(c *gin.Context) {
-	var todoItem db.ToDoItem
-
 	//With HTTP based APIs, a POST request will usually
 	//have a body that contains the data to be added
 	//to the database.  The body is usually JSON, so
 	//we need to bind the JSON to a struct that we
 	//can use in our code.
 	//This framework exposes the raw body via c.Request.Body
-	//but it also provides a helper function ShouldBindJSON()
-	//that will extract the body, convert it to JSON and
-	//bind it to a struct for us.  It will also report an error
-	//if the body is not JSON or if the JSON does not match
-	//the struct we are binding to.
-	if err := c.ShouldBindJSON(&todoItem); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+	//and provides a helper function ShouldBindJSON() that
+	//binds it to a struct for us.  ShouldBindJSON() ignores
+	//fields it does not know about and does not check the
+	//values, so we use db.DecodeItem() instead, it rejects
+	//unknown fields, big bodies and todos that are not valid
+	todoItem, err := db.DecodeItem(c.Request.Body)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	if err := td.db.AddItem(todoItem); err != nil {
-		log.Println("Error adding item: ", err)
-		c.AbortWithStatus(http.StatusConflict)
+	todoItem, err = td.db.AddItem(CurrentUser(c), todoItem)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
+	setETag(c, todoItem)
 	c.JSON(http.StatusOK, todoItem)
 }
 
 // implementation for PUT /todo
-// Web api standards use PUT for Updates
+// Web api standards use PUT for Updates.  With an If-Match header the
+// todo is only updated if it is still at that version, otherwise the
+// caller gets a 412
 func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
-	var todoItem db.ToDoItem
-	if err := c.ShouldBindJSON(&todoItem); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
-	if err := td.db.UpdateItem(todoItem); err != nil {
-		log.Println("Error updating item: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+	todoItem, err := db.DecodeItem(c.Request.Body)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	todoItem, err = td.db.UpdateItem(CurrentUser(c), todoItem, ifVersion)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
+	setETag(c, todoItem)
 	c.JSON(http.StatusOK, todoItem)
 }
 
@@ -201,11 +223,14 @@ func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 // deletes a todo
 func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 	idS := c.Param("id")
-	id64, _ := strconv.ParseInt(idS, 10, 32)
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		httpkit.AbortWithStatus(c, http.StatusBadRequest, "The todo id must be a number")
+		return
+	}
 
-	if err := td.db.DeleteItem(int(id64)); err != nil {
-		log.Println("Error deleting item: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+	if err := td.db.DeleteItem(CurrentUser(c), int(id64)); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
@@ -213,12 +238,11 @@ func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 }
 
 // implementation for DELETE /todo
-// deletes all todos
+// deletes all of the caller's todos, other users are not affected
 func (td *ToDoAPI) DeleteAllToDo(c *gin.Context) {
 
-	if err := td.db.DeleteAll(); err != nil {
-		log.Println("Error deleting all items: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+	if err := td.db.DeleteAll(CurrentUser(c)); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"architectingsoftware.com/httpkit"
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+)
+
+// statusForError picks the HTTP status for an error from the database or
+// from decoding a todo item.  This is the only place that knows how those
+// errors map to statuses, handlers pass their errors to abortWithError
+func statusForError(err error) int {
+	var verr *db.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrItemTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, db.ErrMalformedItem):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrBusy):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, db.ErrInvalidUser):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// abortWithError sends the problem for a database error.  httpkit logs
+// the errors we did not expect, and their details are not sent since they
+// are about redis and not about the request.  An item that is not valid
+// gets an errors member with a line for each field
+func abortWithError(c *gin.Context, err error) {
+	status := statusForError(err)
+	var verr *db.ValidationError
+	if errors.As(err, &verr) {
+		httpkit.AbortWithProblem(c, httpkit.NewProblem(status, err.Error()).With("errors", verr.Fields))
+		return
+	}
+	httpkit.AbortWithErrorStatus(c, status, err)
+}
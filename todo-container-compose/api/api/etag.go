@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"architectingsoftware.com/httpkit"
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+)
+
+//The ETag of a todo is its version in quotes, like "3".  A client sends
+//it back in If-Match when it updates the todo, and the update only
+//happens if nobody changed the todo in the meantime.  Otherwise the
+//client gets a 412 and should GET the todo again to see what changed
+
+// etag returns the ETag header value for a version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag adds the ETag header for an item to the response
+func setETag(c *gin.Context, item db.ToDoItem) {
+	c.Header("ETag", etag(item.Version))
+}
+
+// ifMatchVersion reads the If-Match header of a request.  Without the
+// header, or with *, any version can be updated.  Only a single ETag from
+// this API is understood.  Anything else is a bad request, so a 400 is
+// sent and ok is false
+func ifMatchVersion(c *gin.Context) (version int64, ok bool) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return db.AnyVersion, true
+	}
+
+	//weak ETags, W/"3", never match with If-Match
+	if len(ifMatch) > 2 && strings.HasPrefix(ifMatch, `"`) && strings.HasSuffix(ifMatch, `"`) {
+		if version, err := strconv.ParseInt(ifMatch[1:len(ifMatch)-1], 10, 64); err == nil && version >= 0 {
+			return version, true
+		}
+	}
+	httpkit.AbortWithStatus(c, http.StatusBadRequest, "If-Match must be the ETag of the todo, like \"3\"")
+	return 0, false
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"architectingsoftware.com/httpkit"
+	"drexel.edu/todo/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+// Role is what a user is allowed to do.  Roles are ordered, each role can
+// do everything the roles before it can do.  Roles come from the "roles"
+// claim of the token, a token without roles is a viewer
+type Role string
+
+const (
+	RolePublic Role = ""       //no token needed
+	RoleViewer Role = "viewer" //read todos
+	RoleEditor Role = "editor" //add, change and delete single todos
+	RoleAdmin  Role = "admin"  //delete every todo and the demo failure routes
+)
+
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// hasRole reports if any of the roles is at least the needed role, roles
+// we don't know about are ignored
+func hasRole(roles []string, need Role) bool {
+	if len(roles) == 0 {
+		roles = []string{string(RoleViewer)}
+	}
+	for _, r := range roles {
+		if rank, ok := roleRank[Role(strings.ToLower(r))]; ok && rank >= roleRank[need] {
+			return true
+		}
+	}
+	return false
+}
+
+// auditLog records requests that were denied.  It writes to stderr with
+// its own prefix so it is easy to pick out of the container logs
+var auditLog = log.New(os.Stderr, "AUDIT ", log.LstdFlags|log.LUTC)
+
+// Route is one row of the policy table, the route, the role needed to
+// use it and how often a caller can use it.  A zero Limit uses the
+// default limit of the rate limiter.  Idempotent routes can be retried
+// with an Idempotency-Key header
+type Route struct {
+	Method     string
+	Path       string
+	Role       Role
+	Limit      httpkit.Limit
+	Idempotent bool
+	Handler    gin.HandlerFunc
+}
+
+// RequireRole returns gin middleware that sends a 403 if the caller does
+// not have the role.  It must run after Middleware
+func (a *Authenticator) RequireRole(need Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hasRole(CurrentRoles(c), need) {
+			c.Next()
+			return
+		}
+		auditLog.Printf("denied user=%q roles=%q need=%s method=%s path=%s ip=%s",
+			CurrentUser(c), CurrentRoles(c), need, c.Request.Method, c.Request.URL.Path, c.ClientIP())
+		httpkit.AbortWithStatus(c, http.StatusForbidden, "This needs the "+string(need)+" role")
+	}
+}
+
+// Register adds all of the routes in the policy table.  Routes that need
+// a role check the token first, public routes don't.  Before the token is
+// checked each IP address is limited, so a flood of bad tokens is turned
+// away without checking every one.  The route's own limit is checked
+// after the token so that callers are limited by user.  limiter can be
+// nil to turn rate limiting off.  Idempotency keys are checked last, so
+// requests that are turned away are not saved, idem can also be nil
+func (a *Authenticator) Register(r gin.IRouter, routes []Route, limiter *httpkit.RateLimiter, idem *idempotency.Idempotency) {
+	for _, rt := range routes {
+		handlers := []gin.HandlerFunc{}
+		if rt.Role != RolePublic {
+			if limiter != nil {
+				handlers = append(handlers, limiter.ByIP())
+			}
+			handlers = append(handlers, a.Middleware())
+		}
+		if limiter != nil {
+			handlers = append(handlers, limiter.Handler(rt.Limit))
+		}
+		if rt.Role != RolePublic {
+			handlers = append(handlers, a.RequireRole(rt.Role))
+		}
+		if rt.Idempotent && idem != nil {
+			handlers = append(handlers, idem.Handler())
+		}
+		r.Handle(rt.Method, rt.Path, append(handlers, rt.Handler)...)
+	}
+}
+
+// CallerKey says who the caller is for rate limiting and idempotency
+// keys, the user if they sent a token, otherwise their IP address
+func CallerKey(c *gin.Context) string {
+	if user := CurrentUser(c); user != "" {
+		return "user:" + user
+	}
+	return httpkit.ByIPKey(c)
+}
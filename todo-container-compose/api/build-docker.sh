@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag todo-api-basic:v3  -f ./dockerfile .
+docker build --tag todo-api-basic:v3  -f ./dockerfile --build-context todoitem=../../todoitem --build-context httpkit=../../httpkit .
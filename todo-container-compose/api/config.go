@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/httpkit/config"
+	"drexel.edu/todo/idempotency"
+)
+
+// Config is every setting of the todo API, see the config package in
+// httpkit for how the defaults, a file, the environment and the flags are
+// layered
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Redis       config.Cache      `yaml:"redis" toml:"redis"`
+	Auth        AuthConfig        `yaml:"auth" toml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rateLimit" toml:"rateLimit"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	CORS        config.CORS       `yaml:"cors" toml:"cors"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+}
+
+// ServerConfig is the shared server settings plus the demo routes
+type ServerConfig struct {
+	config.Server `yaml:",inline"`
+	DemoRoutes    bool `yaml:"demoRoutes" toml:"demoRoutes"`
+}
+
+type AuthConfig struct {
+	Alg     string `yaml:"alg" toml:"alg"`
+	KeyFile string `yaml:"keyFile" toml:"keyFile"`
+}
+
+// RateLimitConfig is the shared rate limit settings plus the limit for
+// one IP address, which is checked before the token
+type RateLimitConfig struct {
+	config.RateLimit `yaml:",inline"`
+	PerIP            int `yaml:"perIP" toml:"perIP"` //requests a minute from one IP address, before the token is checked
+}
+
+type IdempotencyConfig struct {
+	Store string          `yaml:"store" toml:"store"` //memory, redis or off
+	TTL   config.Duration `yaml:"ttl" toml:"ttl"`     //how long responses are kept for retries
+}
+
+// LogConfig only has the level, the todo API logs with gin's logger
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"`
+}
+
+// DefaultConfig is good for running the API on a laptop next to a redis
+// container
+func DefaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{Server: config.DefaultServer(1080)},
+		Redis:  config.Cache{Addr: "0.0.0.0:6379"},
+		Auth:   AuthConfig{Alg: "HS256"},
+		RateLimit: RateLimitConfig{
+			RateLimit: config.DefaultRateLimit(),
+			PerIP:     600,
+		},
+		Idempotency: IdempotencyConfig{
+			Store: "memory",
+			TTL:   config.Duration(24 * time.Hour),
+		},
+		CORS: config.DefaultCORS(),
+		Log:  LogConfig{Level: "info"},
+	}
+}
+
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	c.Server.BindFlags(fs)
+	//The crash and kill routes take the API down, they are only there for
+	//the docker compose restart demos so they are off unless asked for
+	fs.BoolVar(&c.Server.DemoRoutes, "enable-demo-routes", c.Server.DemoRoutes, "Add the admin only /crash and /kill routes")
+
+	c.Redis.BindFlags(fs, "redis", "redis")
+
+	//Every todo request needs a bearer token, these say how the tokens are
+	//signed
+	fs.StringVar(&c.Auth.Alg, "auth-alg", c.Auth.Alg, "Token signing algorithm, HS256 or RS256")
+	fs.StringVar(&c.Auth.KeyFile, "auth-key", c.Auth.KeyFile, "File with the HS256 secret or the RS256 public key (PEM)")
+
+	//Callers are limited to a number of requests a minute on each route,
+	//the buckets are kept in memory, or in redis when there are replicas
+	c.RateLimit.BindFlags(fs)
+	fs.IntVar(&c.RateLimit.PerIP, "rate-limit-per-ip", c.RateLimit.PerIP, "Requests a minute from one IP address to the routes that need a token, checked before the token")
+
+	//Clients can retry POST /todo with the same Idempotency-Key and get the
+	//first response back, the responses are kept for the TTL
+	fs.StringVar(&c.Idempotency.Store, "idempotency", c.Idempotency.Store, "Where to keep idempotent responses: memory, redis or off")
+	fs.Var(&c.Idempotency.TTL, "idempotency-ttl", "How long idempotent responses are kept for retries")
+
+	c.CORS.BindFlags(fs)
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "debug, info, warn or error")
+}
+
+// Settings keeps REDIS_URL from before the config package so the docker
+// files still work
+func (c *Config) Settings() []config.Setting {
+	settings := config.ServerSettings("TODO_")
+	settings = append(settings, config.Setting{Flag: "enable-demo-routes", Env: "TODO_ENABLE_DEMO_ROUTES"})
+	settings = append(settings, config.CacheSettings("redis", "redis", "REDIS_")...)
+	settings = append(settings,
+		config.Setting{Flag: "auth-alg", Env: "TODO_AUTH_ALG"},
+		config.Setting{Flag: "auth-key", Env: "TODO_AUTH_KEY_FILE"},
+	)
+	settings = append(settings, config.RateLimitSettings("TODO_")...)
+	settings = append(settings,
+		config.Setting{Flag: "rate-limit-per-ip", Env: "TODO_RATE_LIMIT_PER_IP"},
+		config.Setting{Flag: "idempotency", Env: "TODO_IDEMPOTENCY"},
+		config.Setting{Flag: "idempotency-ttl", Env: "TODO_IDEMPOTENCY_TTL"},
+	)
+	settings = append(settings, config.CORSSettings("TODO_")...)
+	return append(settings, config.Setting{Flag: "log-level", Env: "TODO_LOG_LEVEL"})
+}
+
+func (c *Config) Validate() error {
+	var p config.Problems
+	c.Server.Check(&p)
+	c.Redis.Check(&p)
+
+	alg := strings.ToUpper(c.Auth.Alg)
+	p.Check(alg == "HS256" || alg == "RS256", "auth alg %q must be HS256 or RS256", c.Auth.Alg)
+	p.Check(c.Auth.KeyFile != "", "auth key file is required")
+
+	c.RateLimit.Check(&p)
+	p.Check(c.RateLimit.PerIP > 0, "rate limit per IP must be more than 0")
+
+	p.Check(config.OneOf(c.Idempotency.Store, "memory", "redis", "off"), "idempotency store %q must be memory, redis or off", c.Idempotency.Store)
+	p.Check(c.Idempotency.TTL > 0, "idempotency ttl must be more than 0")
+	//a key is only held for InProgressTTL while its request runs, if a
+	//request could run longer a retry would run it a second time
+	if c.Idempotency.Store != "off" {
+		p.Check(c.Server.WriteTimeout.Std() < idempotency.InProgressTTL,
+			"server write timeout %s must be less than %s when idempotency keys are on", c.Server.WriteTimeout, idempotency.InProgressTTL)
+	}
+
+	c.CORS.Check(&p)
+	p.Check(config.OneOf(c.Log.Level, "debug", "info", "warn", "error"), "log level %q must be debug, info, warn or error", c.Log.Level)
+	return p.Err()
+}
+
+// String is the config as YAML without the redis password, it is logged
+// when the API starts
+func (c *Config) String() string {
+	r := *c
+	r.Redis = r.Redis.Redacted()
+	return config.String(r)
+}
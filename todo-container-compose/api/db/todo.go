@@ -6,17 +6,25 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"regexp"
+	"strings"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
+	"github.com/nitishm/go-rejson/v4/rjs"
 )
 
-// ToDoItem is the struct that represents a single ToDo item
+// ToDoItem is the struct that represents a single ToDo item.  Every
+// item belongs to a user, Owner is always set by the database from the
+// user that saved it, whatever is in the request is ignored.  Version
+// is also set by the database, it starts at 1 and goes up every time the
+// item is updated.  The API sends it as the ETag of the item
 type ToDoItem struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	IsDone bool   `json:"done"`
+	Id      int    `json:"id"`
+	Title   string `json:"title"`
+	IsDone  bool   `json:"done"`
+	Owner   string `json:"owner,omitempty"`
+	Version int64  `json:"version"`
 }
 
 const (
@@ -25,6 +33,36 @@ const (
 	RedisKeyPrefix       = "todo:"
 )
 
+// ErrInvalidUser is returned for user names that can't be used in a redis
+// key.  Users are part of the key, so ':' and the glob characters used by
+// KEYS are not allowed
+var ErrInvalidUser = errors.New("invalid user name")
+
+// The errors below are wrapped with the id of the item, check for them with
+// errors.Is.  The api package turns them into the right HTTP status, any
+// other error is a problem with redis
+var (
+	ErrNotFound        = errors.New("item does not exist")
+	ErrConflict        = errors.New("item already exists")
+	ErrVersionMismatch = errors.New("item was changed since it was read")
+	ErrBusy            = errors.New("item is being changed by others, try again")
+)
+
+// AnyVersion is passed to UpdateItem to update the item whatever its
+// version is
+const AnyVersion int64 = -1
+
+// updateRetries is how many times UpdateItem tries again when the item
+// changes while it is being updated
+const updateRetries = 5
+
+var validUserPattern = regexp.MustCompile(`^[A-Za-z0-9._@+-]{1,128}$`)
+
+// ValidUser reports if user can own todo items
+func ValidUser(user string) bool {
+	return validUserPattern.MatchString(user)
+}
+
 type cache struct {
 	cacheClient *redis.Client
 	jsonHelper  *rejson.Handler
@@ -42,28 +80,28 @@ type ToDo struct {
 
 // New is a constructor function that returns a pointer to a new
 // ToDo struct.  If this is called it uses the default Redis URL
-// with the companion constructor NewWithCacheInstance.
+// with the companion constructor NewWithCacheInstance.  The API gets its
+// redis settings from the config package and uses NewWithOptions
 func New() (*ToDo, error) {
-	//We will use an override if the REDIS_URL is provided as an environment
-	//variable, which is the preferred way to wire up a docker container
-	redisUrl := os.Getenv("REDIS_URL")
-	//This handles the default condition
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-	return NewWithCacheInstance(redisUrl)
+	return NewWithCacheInstance(RedisDefaultLocation)
 }
 
 // NewWithCacheInstance is a constructor function that returns a pointer to a new
 // ToDo struct.  It accepts a string that represents the location of the redis
 // cache.
 func NewWithCacheInstance(location string) (*ToDo, error) {
-
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
+	return NewWithOptions(&redis.Options{
 		Addr: location,
 	})
+}
+
+// NewWithOptions is a constructor function that returns a pointer to a new
+// ToDo struct.  The options say where redis is and how to log in to it,
+// for example the password, database number and TLS settings
+func NewWithOptions(opts *redis.Options) (*ToDo, error) {
+
+	//Connect to redis
+	client := redis.NewClient(opts)
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -95,20 +133,31 @@ func NewWithCacheInstance(location string) (*ToDo, error) {
 	}, nil
 }
 
+// Close closes the connection to redis, the ToDo can't be used after
+func (t *ToDo) Close() error {
+	return t.cacheClient.Close()
+}
+
 //------------------------------------------------------------
 // REDIS HELPERS
 //------------------------------------------------------------
 
-// We will use this later, you can ignore for now
+// JSONGet returns a redis nil error when the key does not exist.  err can
+// be nil, UpdateItem checks the error of every read
 func isRedisNilError(err error) bool {
-	return errors.Is(err, redis.Nil) || err.Error() == RedisNilError
+	return err != nil && (errors.Is(err, redis.Nil) || err.Error() == RedisNilError)
 }
 
 // In redis, our keys will be strings, they will look like
-// todo:<number>.  This function will take an integer and
-// return a string that can be used as a key in redis
-func redisKeyFromId(id int) string {
-	return fmt.Sprintf("%s%d", RedisKeyPrefix, id)
+// todo:<user>:<number>.  This function will take a user and an integer
+// and return a string that can be used as a key in redis
+func redisKeyFromId(user string, id int) string {
+	return fmt.Sprintf("%s%s:%d", RedisKeyPrefix, user, id)
+}
+
+// redisUserPattern matches all of the keys of a user
+func redisUserPattern(user string) string {
+	return RedisKeyPrefix + user + ":*"
 }
 
 // Helper to return a ToDoItem from redis provided a key
@@ -119,6 +168,9 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 	//json structure
 	itemObject, err := t.jsonHelper.JSONGet(key, ".")
 	if err != nil {
+		if isRedisNilError(err) {
+			return ErrNotFound
+		}
 		return err
 	}
 
@@ -149,25 +201,30 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 // Postconditions:
 //
 //	 (1) The item will be added to the DB
-//		(2) The DB file will be saved with the item added
+//		(2) The item that was saved is returned, it is at version 1
 //		(3) If there is an error, it will be returned
-func (t *ToDo) AddItem(item ToDoItem) error {
-
-	//Before we add an item to the DB, lets make sure
-	//it does not exist, if it does, return an error
-	redisKey := redisKeyFromId(item.Id)
-	var existingItem ToDoItem
-	if err := t.getItemFromRedis(redisKey, &existingItem); err == nil {
-		return errors.New("item already exists")
+func (t *ToDo) AddItem(user string, item ToDoItem) (ToDoItem, error) {
+	if !ValidUser(user) {
+		return ToDoItem{}, ErrInvalidUser
 	}
-
-	//Add item to database with JSON Set
-	if _, err := t.jsonHelper.JSONSet(redisKey, ".", item); err != nil {
-		return err
+	item.Owner = user
+	item.Version = 1
+
+	//The item must not exist yet.  Checking first and then saving would
+	//let two adds of the same id both succeed, so we save with NX, which
+	//only sets the key if it is not there.  If it is there redis replies
+	//with nil instead of OK
+	redisKey := redisKeyFromId(user, item.Id)
+	res, err := t.jsonHelper.JSONSet(redisKey, ".", item, rjs.SetOptionNX)
+	if err != nil {
+		return ToDoItem{}, err
+	}
+	if res == nil {
+		return ToDoItem{}, fmt.Errorf("todo %d: %w", item.Id, ErrConflict)
 	}
 
 	//If everything is ok, return nil for the error
-	return nil
+	return item, nil
 }
 
 // DeleteItem accepts an item id and removes it from the DB.
@@ -183,26 +240,36 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 //	 (1) The item will be removed from the DB
 //		(2) The DB file will be saved with the item removed
 //		(3) If there is an error, it will be returned
-func (t *ToDo) DeleteItem(id int) error {
+func (t *ToDo) DeleteItem(user string, id int) error {
+	if !ValidUser(user) {
+		return ErrInvalidUser
+	}
 
-	pattern := redisKeyFromId(id)
+	pattern := redisKeyFromId(user, id)
 	numDeleted, err := t.cacheClient.Del(t.context, pattern).Result()
 	if err != nil {
 		return err
 	}
 	if numDeleted == 0 {
-		return errors.New("attempted to delete non-existent item")
+		return fmt.Errorf("todo %d: %w", id, ErrNotFound)
 	}
 
 	return nil
 }
 
-// DeleteAll removes all items from the DB.
+// DeleteAll removes all of the items of a user from the DB.
 // It will be exposed via a DELETE /todo endpoint
-func (t *ToDo) DeleteAll() error {
+func (t *ToDo) DeleteAll(user string) error {
+	if !ValidUser(user) {
+		return ErrInvalidUser
+	}
 
-	pattern := RedisKeyPrefix + "*"
+	pattern := redisUserPattern(user)
 	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
+	//Nothing to delete, DEL with no keys is an error in redis
+	if len(ks) == 0 {
+		return nil
+	}
 	//Note delete can take a collection of keys.  In go we can
 	//expand a slice into individual arguments by using the ...
 	//operator
@@ -226,29 +293,79 @@ func (t *ToDo) DeleteAll() error {
 //						function must check if the item already
 //	    				exists in the DB, if not, return an error
 //
+//					(3) Unless ifVersion is AnyVersion, the item in the
+//						DB must be at ifVersion, if not ErrVersionMismatch
+//						is returned.  This stops two people that edit the
+//						same item at the same time from losing each
+//						other's changes
+//
+//					(4) If the item keeps changing while it is saved,
+//						ErrBusy is returned after updateRetries tries and
+//						nothing is saved, the caller can try again
+//
 // Postconditions:
 //
 //	 (1) The item will be updated in the DB
-//		(2) The DB file will be saved with the item updated
+//		(2) The item that was saved is returned, with its new version
 //		(3) If there is an error, it will be returned
-func (t *ToDo) UpdateItem(item ToDoItem) error {
-
-	//Before we add an item to the DB, lets make sure
-	//it does not exist, if it does, return an error
-	redisKey := redisKeyFromId(item.Id)
-	var existingItem ToDoItem
-	if err := t.getItemFromRedis(redisKey, &existingItem); err != nil {
-		return errors.New("item does not exist")
+func (t *ToDo) UpdateItem(user string, item ToDoItem, ifVersion int64) (ToDoItem, error) {
+	if !ValidUser(user) {
+		return ToDoItem{}, ErrInvalidUser
 	}
+	item.Owner = user
+	redisKey := redisKeyFromId(user, item.Id)
+
+	//Checking the version and then saving the item are two commands, so
+	//another update could sneak in between them.  Redis solves this with
+	//WATCH and MULTI.  We WATCH the key, read the item, and then save it
+	//in a MULTI transaction.  If anyone changed the key after the WATCH,
+	//redis does not run the transaction and we get a TxFailedErr, so we
+	//read the item again and start over
+	update := func(tx *redis.Tx) error {
+		get := redis.NewCmd(t.context, "JSON.GET", redisKey, ".")
+		_ = tx.Process(t.context, get)
+		itemJson, err := get.Text()
+		if isRedisNilError(err) {
+			return fmt.Errorf("todo %d: %w", item.Id, ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		var existingItem ToDoItem
+		if err := json.Unmarshal([]byte(itemJson), &existingItem); err != nil {
+			return err
+		}
 
-	//Add item to database with JSON Set.  Note there is no update
-	//functionality, so we just overwrite the existing item
-	if _, err := t.jsonHelper.JSONSet(redisKey, ".", item); err != nil {
+		if ifVersion != AnyVersion && existingItem.Version != ifVersion {
+			return fmt.Errorf("todo %d is at version %d, not %d: %w",
+				item.Id, existingItem.Version, ifVersion, ErrVersionMismatch)
+		}
+		item.Version = existingItem.Version + 1
+
+		newJson, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(t.context, func(pipe redis.Pipeliner) error {
+			pipe.Do(t.context, "JSON.SET", redisKey, ".", string(newJson))
+			return nil
+		})
 		return err
 	}
 
-	//If everything is ok, return nil for the error
-	return nil
+	for i := 0; i < updateRetries; i++ {
+		err := t.cacheClient.Watch(t.context, update, redisKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return ToDoItem{}, err
+		}
+		return item, nil
+	}
+	//the item kept changing under us, that is not a version the caller
+	//asked for so it is not ErrVersionMismatch, they can just try again
+	return ToDoItem{}, fmt.Errorf("todo %d: %w", item.Id, ErrBusy)
 }
 
 // GetItem accepts an item id and returns the item from the DB.
@@ -265,15 +382,21 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 //		(2) If there is an error, it will be returned
 //			along with an empty ToDoItem
 //		(3) The database file will not be modified
-func (t *ToDo) GetItem(id int) (ToDoItem, error) {
+func (t *ToDo) GetItem(user string, id int) (ToDoItem, error) {
+	if !ValidUser(user) {
+		return ToDoItem{}, ErrInvalidUser
+	}
 
 	// Check if item exists before trying to get it
 	// this is a good practice, return an error if the
 	// item does not exist
 	var item ToDoItem
-	pattern := redisKeyFromId(id)
+	pattern := redisKeyFromId(user, id)
 	err := t.getItemFromRedis(pattern, &item)
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ToDoItem{}, fmt.Errorf("todo %d: %w", id, ErrNotFound)
+		}
 		return ToDoItem{}, err
 	}
 
@@ -300,14 +423,14 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 //			work.  For example, it should call GetItem() to get the item
 //			from the DB, then it should call UpdateItem() to update the
 //			item in the DB (after the status is changed).
-func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
+func (t *ToDo) ChangeItemDoneStatus(user string, id int, value bool) error {
 
 	//update was successful
 	return errors.New("not implemented")
 }
 
-// GetAllItems returns all items from the DB.  If successful it
-// returns a slice of all of the items to the caller
+// GetAllItems returns all of the items of a user from the DB.  If
+// successful it returns a slice of all of the items to the caller
 // Preconditions:   (1) The database file must exist and be a valid
 //
 // Postconditions:
@@ -316,17 +439,24 @@ func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
 //		(2) If there is an error, it will be returned
 //			along with an empty slice
 //		(3) The database file will not be modified
-func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
+func (t *ToDo) GetAllItems(user string) ([]ToDoItem, error) {
+	if !ValidUser(user) {
+		return nil, ErrInvalidUser
+	}
 
 	//Now that we have the DB loaded, lets crate a slice
 	var toDoList []ToDoItem
-	var toDoItem ToDoItem
 
-	//Lets query redis for all of the items
-	pattern := RedisKeyPrefix + "*"
+	//Lets query redis for all of the items of the user
+	pattern := redisUserPattern(user)
 	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
 	for _, key := range ks {
+		var toDoItem ToDoItem
 		err := t.getItemFromRedis(key, &toDoItem)
+		if errors.Is(err, ErrNotFound) {
+			//deleted since we got the keys
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -358,11 +488,5 @@ func (t *ToDo) PrintAllItems(itemList []ToDoItem) {
 // and updates in JSON format.  We need to convert it to a ToDoItem
 // struct to perform any operations on it.
 func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
-	var item ToDoItem
-	err := json.Unmarshal([]byte(jsonString), &item)
-	if err != nil {
-		return ToDoItem{}, err
-	}
-
-	return item, nil
+	return DecodeItem(strings.NewReader(jsonString))
 }
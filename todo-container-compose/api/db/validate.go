@@ -0,0 +1,37 @@
+package db
+
+import (
+	"io"
+
+	"drexel.edu/todoitem"
+)
+
+// The rules for todo items are in the todoitem module, the todo CLI uses
+// the same ones.  These names are kept so callers don't need to know that
+const (
+	MaxTitleLength = todoitem.MaxTitleLength
+	MaxItemBytes   = todoitem.MaxItemBytes
+)
+
+var (
+	ErrItemTooLarge  = todoitem.ErrItemTooLarge
+	ErrMalformedItem = todoitem.ErrMalformedItem
+)
+
+type (
+	FieldError      = todoitem.FieldError
+	ValidationError = todoitem.ValidationError
+)
+
+// Validate runs every rule on the item, all of the fields that are not
+// valid are returned in a *ValidationError
+func (item ToDoItem) Validate() error {
+	return todoitem.Check(todoitem.Fields{Id: item.Id, Title: item.Title})
+}
+
+// DecodeItem reads a todo item from JSON and validates it, the API uses it
+// for the body of POST and PUT /todo.  Owner is accepted but the database
+// always replaces it.  See todoitem.Decode for what is rejected
+func DecodeItem(r io.Reader) (ToDoItem, error) {
+	return todoitem.Decode[ToDoItem](r)
+}
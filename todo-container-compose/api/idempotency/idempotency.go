@@ -0,0 +1,213 @@
+// Package idempotency lets clients retry POST requests safely.  The client
+// sends an Idempotency-Key header with a value that is unique to the
+// request, like a UUID, and sends the same key again when it retries.  The
+// first response for a key is saved, and retries get the saved response
+// instead of running the request again.  Saved responses expire after a
+// TTL.  They are kept in memory for a single instance, or in redis so
+// that replicas share them.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	Header = "Idempotency-Key"
+
+	// ReplayedHeader is set to true on responses that were saved earlier
+	ReplayedHeader = "Idempotent-Replayed"
+
+	MaxKeyLength = 255
+
+	// MaxBodyBytes is the most of a request body that is read to check
+	// that a retry has the same body, the handler can still allow less
+	MaxBodyBytes = 1 << 20
+
+	// InProgressTTL is how long a key is held while its first request
+	// runs.  It is short so that a key is not stuck if the API dies in
+	// the middle of a request, and longer than the server write timeout
+	InProgressTTL = time.Minute
+)
+
+// savedHeaders are the response headers that are replayed, the others,
+// like the rate limit headers, are about the retry and not the request
+var savedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Record is what is kept for a key
+type Record struct {
+	BodyHash string      `json:"bodyHash"` //of the request, to catch keys used for a different request
+	Done     bool        `json:"done"`     //false while the first request runs
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+}
+
+// Store keeps the records
+type Store interface {
+	// Begin saves an in progress record for the key, if the key is not
+	// used yet.  Otherwise it returns the record that is there and
+	// started is false
+	Begin(ctx context.Context, key string, rec Record) (existing Record, started bool, err error)
+	// Finish replaces the in progress record with the response
+	Finish(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Abandon removes the record so the request can be tried again
+	Abandon(ctx context.Context, key string) error
+	Close() error
+}
+
+// Idempotency builds the middleware for the routes that support
+// Idempotency-Key
+type Idempotency struct {
+	store   Store
+	keyFunc func(c *gin.Context) string
+	ttl     time.Duration
+}
+
+// New returns an Idempotency that keeps responses in store for ttl.
+// keyFunc says who the caller is, keys are only shared by requests from
+// the same caller to the same route
+func New(store Store, keyFunc func(c *gin.Context) string, ttl time.Duration) *Idempotency {
+	return &Idempotency{store: store, keyFunc: keyFunc, ttl: ttl}
+}
+
+// Close releases the store, it is called when the API shuts down
+func (idem *Idempotency) Close() error {
+	return idem.store.Close()
+}
+
+// Handler returns the middleware.  Requests without an Idempotency-Key
+// header are run as usual.  For a new key the request is run and its
+// response is saved, unless it is a 5xx, then the client can try again.
+// A key that was used before gets:
+//
+//   - the saved response, if the body is the same
+//   - a 422, if the body is different
+//   - a 409, if the first request with the key is still running
+//
+// If the store can't be reached the request is run without the key, like
+// the rate limiter an outage of the cache should not take the API down
+func (idem *Idempotency) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idemKey := c.GetHeader(Header)
+		if idemKey == "" {
+			c.Next()
+			return
+		}
+		if len(idemKey) > MaxKeyLength {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "The Idempotency-Key header can be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, MaxBodyBytes))
+		if err != nil {
+			httpkit.AbortWithStatus(c, http.StatusBadRequest, "Could not read the request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(sum[:])
+
+		ctx := c.Request.Context()
+		key := c.Request.Method + " " + c.FullPath() + ":" + idem.keyFunc(c) + ":" + idemKey
+		existing, started, err := idem.store.Begin(ctx, key, Record{BodyHash: bodyHash})
+		if err != nil {
+			log.Println("Error checking idempotency key, running request: ", err)
+			c.Next()
+			return
+		}
+
+		if !started {
+			switch {
+			case existing.BodyHash != bodyHash:
+				httpkit.AbortWithStatus(c, http.StatusUnprocessableEntity, "The Idempotency-Key was already used for a request with a different body")
+			case !existing.Done:
+				httpkit.AbortWithStatus(c, http.StatusConflict, "A request with this Idempotency-Key is still running, try again later")
+			default:
+				replay(c, existing)
+			}
+			return
+		}
+
+		//a panic in the handler is a 500, the record is removed so the
+		//client can try again, and the panic goes on to the recovery
+		defer func() {
+			if p := recover(); p != nil {
+				idem.abandon(key)
+				panic(p)
+			}
+		}()
+
+		rw := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rw
+		c.Next()
+		c.Writer = rw.ResponseWriter
+
+		status := rw.Status()
+		if status >= http.StatusInternalServerError {
+			idem.abandon(key)
+			return
+		}
+		rec := Record{
+			BodyHash: bodyHash,
+			Done:     true,
+			Status:   status,
+			Header:   http.Header{},
+			Body:     rw.body.Bytes(),
+		}
+		for _, h := range savedHeaders {
+			if v := rw.Header().Get(h); v != "" {
+				rec.Header.Set(h, v)
+			}
+		}
+		//Context() of the request is cancelled if the client went away,
+		//the response still needs to be saved for its retry
+		if err := idem.store.Finish(context.Background(), key, rec, idem.ttl); err != nil {
+			log.Println("Error saving idempotent response: ", err)
+		}
+	}
+}
+
+func (idem *Idempotency) abandon(key string) {
+	if err := idem.store.Abandon(context.Background(), key); err != nil {
+		log.Println("Error removing idempotency key: ", err)
+	}
+}
+
+// replay sends a saved response
+func replay(c *gin.Context, rec Record) {
+	for h, values := range rec.Header {
+		for _, v := range values {
+			c.Writer.Header().Add(h, v)
+		}
+	}
+	c.Header(ReplayedHeader, "true")
+	c.Abort()
+	c.Status(rec.Status)
+	c.Writer.Write(rec.Body)
+}
+
+// recorder keeps a copy of the response body as it is written
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
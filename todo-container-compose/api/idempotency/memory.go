@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many calls to Begin go by between removing expired
+// records
+const sweepEvery = 1000
+
+type entry struct {
+	rec     Record
+	expires time.Time
+}
+
+// MemoryStore keeps the records in this process, it is only right when
+// there is a single instance of the API
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	calls   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*entry), now: time.Now}
+}
+
+func (m *MemoryStore) Begin(ctx context.Context, key string, rec Record) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%sweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		return e.rec, false, nil
+	}
+	m.entries[key] = &entry{rec: rec, expires: now.Add(InProgressTTL)}
+	return Record{}, true, nil
+}
+
+func (m *MemoryStore) Finish(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = &entry{rec: rec, expires: m.now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Abandon(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// Close does nothing, the records go away with the process
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
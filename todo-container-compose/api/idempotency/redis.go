@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const RedisKeyPrefix = "idempotency:"
+
+// RedisStore keeps the records in redis as JSON strings, use it when there
+// is more than one instance of the API.  SET NX makes sure only one
+// replica runs the first request for a key
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Close closes the redis client that was passed to NewRedisStore
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
+
+// beginScript saves the in progress record if the key is not used yet and
+// returns 1, otherwise it returns the record that is there.  It is one
+// script so the record can't expire or be abandoned between the SET NX and
+// the GET
+var beginScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return redis.call("GET", KEYS[1])
+`)
+
+func (r *RedisStore) Begin(ctx context.Context, key string, rec Record) (Record, bool, error) {
+	val, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, false, err
+	}
+	res, err := beginScript.Run(ctx, r.client, []string{RedisKeyPrefix + key}, val, InProgressTTL.Milliseconds()).Result()
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	existing, ok := res.(string)
+	if !ok {
+		return Record{}, true, nil
+	}
+	var found Record
+	if err := json.Unmarshal([]byte(existing), &found); err != nil {
+		return Record{}, false, err
+	}
+	return found, false, nil
+}
+
+func (r *RedisStore) Finish(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	val, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, RedisKeyPrefix+key, val, ttl).Err()
+}
+
+func (r *RedisStore) Abandon(ctx context.Context, key string) error {
+	return r.client.Del(ctx, RedisKeyPrefix+key).Err()
+}
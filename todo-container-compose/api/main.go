@@ -1,87 +1,208 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"architectingsoftware.com/httpkit"
+	"architectingsoftware.com/httpkit/config"
 	"drexel.edu/todo/api"
+	"drexel.edu/todo/idempotency"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
-// Global variables to hold the command line flags to drive the todo CLI
-// application
-var (
-	hostFlag string
-	portFlag uint
-)
+// main is the entry point for our todo API application.  It loads the
+// config, see config.go for the settings, and then starts the API
+func main() {
+	cfg := DefaultConfig()
+	if err := config.Load(cfg, os.Args[1:], "TODO_CONFIG"); err != nil {
+		fmt.Println("Error in the config:", err)
+		os.Exit(1)
+	}
+	//The passwords are starred out so this is safe to log
+	log.Printf("Config:\n%s", cfg)
 
-// processCmdLineFlags parses the command line flags for our CLI
-//
-// TODO: This function uses the flag package to parse the command line
-//		 flags.  The flag package is not very flexible and can lead to
-//		 some confusing code.
-
-//			 REQUIRED:     Study the code below, and make sure you understand
-//						   how it works.  Go online and readup on how the
-//						   flag package works.  Then, write a nice comment
-//				  		   block to document this function that highights that
-//						   you understand how it works.
-//
-//			 EXTRA CREDIT: The best CLI and command line processor for
-//						   go is called Cobra.  Refactor this function to
-//						   use it.  See github.com/spf13/cobra for information
-//						   on how to use it.
-//
-//	 YOUR ANSWER: <GOES HERE>
-func processCmdLineFlags() {
-
-	//Note some networking lingo, some frameworks start the server on localhost
-	//this is a local-only interface and is fine for testing but its not accessible
-	//from other machines.  To make the server accessible from other machines, we
-	//need to listen on an interface, that could be an IP address, but modern
-	//cloud servers may have multiple network interfaces for scale.  With TCP/IP
-	//the address 0.0.0.0 instructs the network stack to listen on all interfaces
-	//We set this up as a flag so that we can overwrite it on the command line if
-	//needed
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
-
-	flag.Parse()
-}
+	if cfg.Log.Level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	//gin.Default() with a recovery that sends a problem like every other
+	//error, a panic in a handler such as /crash is a 500 and the API
+	//keeps running
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		httpkit.AbortWithStatus(c, http.StatusInternalServerError, httpkit.InternalErrorDetail)
+	}))
+	r.Use(cors.New(corsConfig(cfg)))
+	r.NoRoute(httpkit.NoRoute)
 
-// main is the entry point for our todo API application.  It processes
-// the command line flags and then uses the db package to perform the
-// requested operation
-func main() {
-	processCmdLineFlags()
-	r := gin.Default()
-	r.Use(cors.Default())
+	redisOpts, err := cfg.Redis.RedisOptions()
+	if err != nil {
+		fmt.Println("Error setting up redis:", err)
+		os.Exit(1)
+	}
 
-	apiHandler, err := api.New()
+	apiHandler, err := api.New(redisOpts)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r.GET("/todo", apiHandler.ListAllTodos)
-	r.POST("/todo", apiHandler.AddToDo)
-	r.PUT("/todo", apiHandler.UpdateToDo)
-	r.DELETE("/todo", apiHandler.DeleteAllToDo)
-	r.DELETE("/todo/:id", apiHandler.DeleteToDo)
-	r.GET("/todo/:id", apiHandler.GetToDo)
+	auth, err := api.NewAuthenticator(cfg.Auth.Alg, cfg.Auth.KeyFile)
+	if err != nil {
+		fmt.Println("Error setting up token authentication:", err)
+		os.Exit(1)
+	}
+
+	limiter, err := newRateLimiter(cfg, redisOpts)
+	if err != nil {
+		fmt.Println("Error setting up rate limiting:", err)
+		os.Exit(1)
+	}
+
+	idem, err := newIdempotency(cfg, redisOpts)
+	if err != nil {
+		fmt.Println("Error setting up idempotency keys:", err)
+		os.Exit(1)
+	}
 
-	r.GET("/crash", apiHandler.CrashSim)
-	r.GET("/kill", apiHandler.KillSim)
-	r.GET("/health", apiHandler.HealthCheck)
+	//This is the policy table, every route, the role that is needed to
+	//use it and how often it can be used.  The todo routes all need a
+	//token, the todos that they work with belong to the user in the token.
+	//Callers without the role get a 403 and are written to the audit log,
+	//callers over the limit get a 429.  Adding a todo can be retried with
+	//an Idempotency-Key, PUT and DELETE are already safe to repeat
+	writeLimit := httpkit.PerMinute(30)
+	routes := []api.Route{
+		{Method: http.MethodGet, Path: "/todo", Role: api.RoleViewer, Handler: apiHandler.ListAllTodos},
+		{Method: http.MethodGet, Path: "/todo/:id", Role: api.RoleViewer, Handler: apiHandler.GetToDo},
+		{Method: http.MethodPost, Path: "/todo", Role: api.RoleEditor, Limit: writeLimit, Idempotent: true, Handler: apiHandler.AddToDo},
+		{Method: http.MethodPut, Path: "/todo", Role: api.RoleEditor, Limit: writeLimit, Handler: apiHandler.UpdateToDo},
+		{Method: http.MethodDelete, Path: "/todo/:id", Role: api.RoleEditor, Limit: writeLimit, Handler: apiHandler.DeleteToDo},
+		{Method: http.MethodDelete, Path: "/todo", Role: api.RoleAdmin, Limit: httpkit.PerMinute(5), Handler: apiHandler.DeleteAllToDo},
 
-	//We will now show a common way to version an API and add a new
-	//version of an API handler under /v2.  This new API will support
-	//a path parameter to search for todos based on a status
-	v2 := r.Group("/v2")
-	v2.GET("/todo", apiHandler.ListSelectTodos)
+		//We will now show a common way to version an API and add a new
+		//version of an API handler under /v2.  This new API will support
+		//a path parameter to search for todos based on a status
+		{Method: http.MethodGet, Path: "/v2/todo", Role: api.RoleViewer, Handler: apiHandler.ListSelectTodos},
+
+		{Method: http.MethodGet, Path: "/health", Role: api.RolePublic, Handler: apiHandler.HealthCheck},
+	}
+	if cfg.Server.DemoRoutes {
+		routes = append(routes,
+			api.Route{Method: http.MethodGet, Path: "/crash", Role: api.RoleAdmin, Handler: apiHandler.CrashSim},
+			api.Route{Method: http.MethodGet, Path: "/kill", Role: api.RoleAdmin, Handler: apiHandler.KillSim},
+		)
+	}
+	auth.Register(r, routes, limiter, idem)
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr(),
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout.Std(),
+		WriteTimeout: cfg.Server.WriteTimeout.Std(),
+		IdleTimeout:  cfg.Server.IdleTimeout.Std(),
+	}
+	if err := serve(srv, cfg.Server.ShutdownGrace.Std()); err != nil {
+		fmt.Println(err)
+	}
+
+	//The server has stopped so nothing is using redis anymore
+	if limiter != nil {
+		if err := limiter.Close(); err != nil {
+			log.Println("Error closing rate limiter: ", err)
+		}
+	}
+	if idem != nil {
+		if err := idem.Close(); err != nil {
+			log.Println("Error closing idempotency store: ", err)
+		}
+	}
+	if err := apiHandler.Close(); err != nil {
+		log.Println("Error closing database: ", err)
+	}
+	log.Println("Stopped")
+}
+
+// serve runs the server until it gets SIGINT or SIGTERM, which is what
+// docker sends to stop a container.  It then stops taking new connections
+// and gives the requests that are running the grace period to finish
+func serve(srv *http.Server, grace time.Duration) error {
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		//the server could not start, for example the port is in use
+		return err
+	case <-stopCtx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for requests to finish", grace)
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("requests did not finish in time: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newRateLimiter sets up the rate limiter picked with -rate-limit, it is
+// nil when rate limiting is off.  The redis store gets its own client so
+// it can be closed on its own.  The limiter is the one in httpkit that the
+// other APIs in this repo use, callers with a token are counted by user
+func newRateLimiter(cfg *Config, redisOpts *redis.Options) (*httpkit.RateLimiter, error) {
+	store, err := httpkit.NewRateStore(cfg.RateLimit.Store, redisOpts)
+	if err != nil || store == nil {
+		return nil, err
+	}
+	limiter := httpkit.NewRateLimiter(store, httpkit.PerMinute(cfg.RateLimit.Default))
+	limiter.PerIP = httpkit.PerMinute(cfg.RateLimit.PerIP)
+	limiter.Key = api.CallerKey
+	return limiter, nil
+}
+
+// newIdempotency sets up the store for idempotency keys picked with
+// -idempotency, it is nil when they are off.  Like the rate limiter the
+// redis store gets its own client
+func newIdempotency(cfg *Config, redisOpts *redis.Options) (*idempotency.Idempotency, error) {
+	var store idempotency.Store
+	switch cfg.Idempotency.Store {
+	case "off":
+		return nil, nil
+	case "memory":
+		store = idempotency.NewMemoryStore()
+	case "redis":
+		store = idempotency.NewRedisStore(redis.NewClient(redisOpts))
+	default:
+		return nil, fmt.Errorf("unknown idempotency store %q, use memory, redis or off", cfg.Idempotency.Store)
+	}
+	return idempotency.New(store, api.CallerKey, cfg.Idempotency.TTL.Std()), nil
+}
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+// corsConfig lets browsers call the API from the configured origins, a *
+// in the list allows any origin
+func corsConfig(cfg *Config) cors.Config {
+	c := httpkit.CORSConfig(cfg.CORS.Origins)
+	c.AllowHeaders = append(c.AllowHeaders, "Authorization", "If-Match", "If-None-Match", idempotency.Header)
+	c.ExposeHeaders = append(c.ExposeHeaders, "ETag", idempotency.ReplayedHeader)
+	return c
 }
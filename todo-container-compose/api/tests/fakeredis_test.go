@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis answers the commands the todo database sends, JSON.GET,
+// JSON.SET and the WATCH, MULTI and EXEC that UpdateItem saves with, so
+// the handlers can be tested without a redis that has the JSON module.
+// Each write bumps the version of its key, and EXEC is turned down if a
+// watched key was written after the WATCH, like redis does
+type fakeRedis struct {
+	mu       sync.Mutex
+	data     map[string]string
+	versions map[string]int
+
+	//busy turns down every EXEC, as if the item kept being changed
+	busy bool
+}
+
+// conn is what redis keeps for each connection
+type conn struct {
+	watched map[string]int
+	queued  [][]string //nil unless MULTI was sent
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, *redis.Options) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	f := &fakeRedis{data: map[string]string{}, versions: map[string]int{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f, &redis.Options{Addr: ln.Addr().String()}
+}
+
+func (f *fakeRedis) setBusy(busy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.busy = busy
+}
+
+func (f *fakeRedis) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	c := &conn{watched: map[string]int{}}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		io.WriteString(nc, f.reply(c, args))
+	}
+}
+
+// readCommand reads one command, which redis clients send as an array of
+// bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) reply(c *conn, args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cmd := strings.ToUpper(args[0])
+	if c.queued != nil && cmd != "EXEC" {
+		c.queued = append(c.queued, args)
+		return "+QUEUED\r\n"
+	}
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "WATCH":
+		for _, key := range args[1:] {
+			c.watched[key] = f.versions[key]
+		}
+		return "+OK\r\n"
+	case "UNWATCH":
+		c.watched = map[string]int{}
+		return "+OK\r\n"
+	case "MULTI":
+		c.queued = [][]string{}
+		return "+OK\r\n"
+	case "EXEC":
+		queued := c.queued
+		c.queued = nil
+		changed := f.busy
+		for key, version := range c.watched {
+			changed = changed || f.versions[key] != version
+		}
+		c.watched = map[string]int{}
+		if changed {
+			return "*-1\r\n"
+		}
+		out := fmt.Sprintf("*%d\r\n", len(queued))
+		for _, q := range queued {
+			out += f.run(q)
+		}
+		return out
+	default:
+		return f.run(args)
+	}
+}
+
+func (f *fakeRedis) run(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "JSON.SET":
+		_, exists := f.data[args[1]]
+		if exists && len(args) > 4 && strings.ToUpper(args[4]) == "NX" {
+			return "$-1\r\n"
+		}
+		f.data[args[1]] = args[3]
+		f.versions[args[1]]++
+		return "+OK\r\n"
+	case "JSON.GET":
+		v, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	default:
+		return "-ERR unknown command " + args[0] + "\r\n"
+	}
+}
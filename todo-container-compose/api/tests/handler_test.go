@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+	"drexel.edu/todo/api"
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter has the handlers that read a todo from the body.  The body is
+// checked before the database is used, so the tests don't need redis
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	td := &api.ToDoAPI{}
+	r := gin.New()
+	r.POST("/todo", td.AddToDo)
+	r.PUT("/todo", td.UpdateToDo)
+	return r
+}
+
+// problemBody is a problem with the field errors of an invalid item
+type problemBody struct {
+	httpkit.Problem
+	Errors []db.FieldError `json:"errors"`
+}
+
+func send(r *gin.Engine, method, body string) (*httptest.ResponseRecorder, problemBody) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/todo", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	var p problemBody
+	json.Unmarshal(w.Body.Bytes(), &p)
+	return w, p
+}
+
+func TestHandlersRejectInvalidItems(t *testing.T) {
+	r := newRouter()
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		w, p := send(r, method, `{"id": -1, "title": "", "priority": 1}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, method)
+		assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"), method)
+		assert.Equal(t, []db.FieldError{
+			{Field: "priority", Message: "is not a todo item field"},
+		}, p.Errors, method)
+
+		w, p = send(r, method, `{"id": -1, "title": ""}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, method)
+		assert.Equal(t, []db.FieldError{
+			{Field: "id", Message: "must be 1 or more"},
+			{Field: "title", Message: "is required"},
+		}, p.Errors, method)
+	}
+}
+
+func TestHandlersRejectLargeItems(t *testing.T) {
+	r := newRouter()
+	body := `{"id": 1, "title": "` + strings.Repeat("a", db.MaxItemBytes) + `"}`
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		w, p := send(r, method, body)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, method)
+		assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"), method)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, p.Status, method)
+		assert.Empty(t, p.Errors, method)
+	}
+}
+
+func TestHandlersRejectMalformedItems(t *testing.T) {
+	r := newRouter()
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		w, _ := send(r, method, `{"id": 1, "title": "a"`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, method)
+	}
+}
+
+// newStoreRouter has the handlers that use the database, in front of a
+// fake redis.  The secret is made up for each test and token is signed
+// with it for the user alice
+func newStoreRouter(t *testing.T) (r *gin.Engine, redis *fakeRedis, token string) {
+	gin.SetMode(gin.TestMode)
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "hs256.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(hex.EncodeToString(secret)), 0600))
+
+	auth, err := api.NewAuthenticator(api.AlgHS256, keyFile)
+	require.NoError(t, err)
+	token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "alice",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(hex.EncodeToString(secret)))
+	require.NoError(t, err)
+
+	redis, opts := startFakeRedis(t)
+	td, err := api.New(opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { td.Close() })
+
+	r = gin.New()
+	r.Use(auth.Middleware())
+	r.GET("/todo/:id", td.GetToDo)
+	r.POST("/todo", td.AddToDo)
+	r.PUT("/todo", td.UpdateToDo)
+	return r, redis, token
+}
+
+func sendWith(r *gin.Engine, method, path, body string, headers map[string]string) (*httptest.ResponseRecorder, problemBody) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(w, req)
+
+	var p problemBody
+	json.Unmarshal(w.Body.Bytes(), &p)
+	return w, p
+}
+
+func TestUpdateWithIfMatch(t *testing.T) {
+	r, _, token := newStoreRouter(t)
+	bearer := "Bearer " + token
+	w, _ := sendWith(r, http.MethodPost, "/todo", `{"id": 1, "title": "write tests"}`, map[string]string{"Authorization": bearer})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	//the ETag the todo has now is saved, and the new one is sent back
+	w, _ = sendWith(r, http.MethodPut, "/todo", `{"id": 1, "title": "write more tests"}`,
+		map[string]string{"Authorization": bearer, "If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "write more tests")
+
+	//someone else has the todo at version 1 still
+	w, p := sendWith(r, http.MethodPut, "/todo", `{"id": 1, "title": "lost update"}`,
+		map[string]string{"Authorization": bearer, "If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, p.Detail, "is at version 2, not 1")
+
+	for _, ifMatch := range []string{`W/"2"`, `"2", "3"`, `2`, `"two"`, `"-1"`} {
+		w, _ = sendWith(r, http.MethodPut, "/todo", `{"id": 1, "title": "bad header"}`,
+			map[string]string{"Authorization": bearer, "If-Match": ifMatch})
+		assert.Equal(t, http.StatusBadRequest, w.Code, ifMatch)
+	}
+
+	w, _ = sendWith(r, http.MethodGet, "/todo/1", "", map[string]string{"Authorization": bearer})
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "write more tests")
+}
+
+func TestGetWithIfNoneMatch(t *testing.T) {
+	r, _, token := newStoreRouter(t)
+	bearer := "Bearer " + token
+	w, _ := sendWith(r, http.MethodPost, "/todo", `{"id": 1, "title": "write tests"}`, map[string]string{"Authorization": bearer})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w, _ = sendWith(r, http.MethodGet, "/todo/1", "", map[string]string{"Authorization": bearer, "If-None-Match": `"1"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.String())
+
+	w, _ = sendWith(r, http.MethodGet, "/todo/1", "", map[string]string{"Authorization": bearer, "If-None-Match": `"0"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "write tests")
+}
+
+func TestUpdateWhileBusy(t *testing.T) {
+	r, redis, token := newStoreRouter(t)
+	bearer := "Bearer " + token
+	w, _ := sendWith(r, http.MethodPost, "/todo", `{"id": 1, "title": "write tests"}`, map[string]string{"Authorization": bearer})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	//the todo changes every time it is saved, so the retries run out.
+	//That isn't a version mismatch, without If-Match there is no version
+	redis.setBusy(true)
+	w, p := sendWith(r, http.MethodPut, "/todo", `{"id": 1, "title": "busy"}`, map[string]string{"Authorization": bearer})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, p.Detail, db.ErrBusy.Error())
+
+	//nothing was saved, so sending it again works
+	redis.setBusy(false)
+	w, _ = sendWith(r, http.MethodPut, "/todo", `{"id": 1, "title": "busy"}`, map[string]string{"Authorization": bearer})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"architectingsoftware.com/httpkit"
+	"drexel.edu/todo/idempotency"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIdempotentRouter has a POST /todo that counts how often it runs.  If
+// hold is not nil the handler sends on it when it starts and then waits
+// for a receive on it, to keep a request running
+func newIdempotentRouter(runs *int, hold chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	idem := idempotency.New(idempotency.NewMemoryStore(), func(c *gin.Context) string {
+		return c.GetHeader("X-User")
+	}, time.Hour)
+
+	var mu sync.Mutex
+	r := gin.New()
+	r.POST("/todo", idem.Handler(), func(c *gin.Context) {
+		mu.Lock()
+		*runs++
+		mu.Unlock()
+		if hold != nil {
+			hold <- struct{}{}
+			<-hold
+		}
+		c.Header("ETag", `"1"`)
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+	return r
+}
+
+func post(r *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/todo", strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var runs int
+	r := newIdempotentRouter(&runs, nil)
+
+	first := post(r, "bob", "k1", `{"id": 1}`)
+	retry := post(r, "bob", "k1", `{"id": 1}`)
+
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, `"1"`, retry.Header().Get("ETag"))
+	assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+	assert.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+}
+
+func TestIdempotencyKeysBelongToCaller(t *testing.T) {
+	var runs int
+	r := newIdempotentRouter(&runs, nil)
+
+	post(r, "bob", "k1", `{"id": 1}`)
+	w := post(r, "alice", "k1", `{"id": 1}`)
+
+	assert.Equal(t, 2, runs)
+	assert.Empty(t, w.Header().Get(idempotency.ReplayedHeader))
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	var runs int
+	r := newIdempotentRouter(&runs, nil)
+
+	post(r, "bob", "k1", `{"id": 1}`)
+	w := post(r, "bob", "k1", `{"id": 2}`)
+
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"))
+}
+
+func TestIdempotencyConflictWhileRunning(t *testing.T) {
+	var runs int
+	hold := make(chan struct{})
+	r := newIdempotentRouter(&runs, hold)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(r, "bob", "k1", `{"id": 1}`) }()
+	<-hold //the first request is running and holds the key
+
+	w := post(r, "bob", "k1", `{"id": 1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, httpkit.ProblemContentType, w.Header().Get("Content-Type"))
+
+	hold <- struct{}{}
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+	assert.Equal(t, 1, runs)
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	var runs int
+	r := newIdempotentRouter(&runs, nil)
+
+	post(r, "bob", "", `{"id": 1}`)
+	post(r, "bob", "", `{"id": 1}`)
+
+	assert.Equal(t, 2, runs)
+}
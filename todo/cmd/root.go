@@ -243,8 +243,7 @@ var rootCmd = &cobra.Command{
 			fmt.Println("Running ADD_DB_ITEM...")
 			item, err := todo.JsonToItem(addFlag)
 			if err != nil {
-				fmt.Println("Add option requires a valid JSON todo item string")
-				fmt.Println("Error: ", err)
+				printItemError("Add", err)
 				break
 			}
 			if err := todo.AddItem(item); err != nil {
@@ -256,8 +255,7 @@ var rootCmd = &cobra.Command{
 			fmt.Println("Running UPDATE_DB_ITEM...")
 			item, err := todo.JsonToItem(updateFlag)
 			if err != nil {
-				fmt.Println("Update option requires a valid JSON todo item string")
-				fmt.Println("Error: ", err)
+				printItemError("Update", err)
 				break
 			}
 			if err := todo.UpdateItem(item); err != nil {
@@ -292,6 +290,21 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// printItemError explains why the JSON todo item given to --add or
+// --update was not accepted, with a line for each field that is wrong
+func printItemError(option string, err error) {
+	var verr *db.ValidationError
+	if errors.As(err, &verr) {
+		fmt.Println(option, "option was given a todo item that is not valid:")
+		for _, f := range verr.Fields {
+			fmt.Printf("  %s %s\n", f.Field, f.Message)
+		}
+		return
+	}
+	fmt.Println(option, "option requires a valid JSON todo item string")
+	fmt.Println("Error: ", err)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // ToDoItem is the struct that represents a single ToDo item
@@ -392,15 +393,10 @@ func (t *ToDo) PrintAllItems(itemList []ToDoItem) {
 // JsonToItem accepts a json string and returns a ToDoItem
 // This is helpful because the CLI accepts todo items for insertion
 // and updates in JSON format.  We need to convert it to a ToDoItem
-// struct to perform any operations on it.
+// struct to perform any operations on it.  The item is checked the
+// same way the API checks them, see DecodeItem
 func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
-	var item ToDoItem
-	err := json.Unmarshal([]byte(jsonString), &item)
-	if err != nil {
-		return ToDoItem{}, err
-	}
-
-	return item, nil
+	return DecodeItem(strings.NewReader(jsonString))
 }
 
 // ChangeItemDoneStatus accepts an item id and a boolean status.
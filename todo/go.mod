@@ -12,3 +12,7 @@ require (
 	github.com/stretchr/testify v1.8.4 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+require drexel.edu/todoitem v0.0.0
+
+replace drexel.edu/todoitem => ../todoitem
@@ -0,0 +1,17 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// The rules themselves are tested in the todoitem module, this only checks
+// that the CLI runs them
+func TestJsonToItemValidates(t *testing.T) {
+	_, err := DB.JsonToItem(`{"id": 0, "title": "no id"}`)
+	var verr *db.ValidationError
+	assert.True(t, errors.As(err, &verr))
+}
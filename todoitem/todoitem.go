@@ -0,0 +1,168 @@
+// Package todoitem has the rules for todo items that are read from users.
+// The todo API and the todo CLI both use it, so an item the CLI takes is
+// also one the API takes.  It is its own module because the API and the
+// CLI are both drexel.edu/todo and can't import each other, and it is at
+// the top of the repo so neither one reaches inside the other.
+package todoitem
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on the todo items that are read from users
+const (
+	MaxTitleLength = 200     //characters
+	MaxItemBytes   = 4 << 10 //the biggest JSON todo item we will read
+)
+
+var (
+	// ErrItemTooLarge is returned by Decode when the JSON is bigger than
+	// MaxItemBytes
+	ErrItemTooLarge = fmt.Errorf("todo item is bigger than %d bytes", MaxItemBytes)
+
+	// ErrMalformedItem is returned by Decode when the input is not a
+	// single JSON object
+	ErrMalformedItem = errors.New("todo item is not a JSON object")
+)
+
+// FieldError says what is wrong with one field of a todo item
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of a todo item that is not valid
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid todo item: " + strings.Join(msgs, "; ")
+}
+
+// Fields are the fields of a todo item that have rules.  The API and the
+// CLI each have their own ToDoItem, they pass these fields to Check
+type Fields struct {
+	Id    int
+	Title string
+}
+
+// rules are the checks for each field of a todo item.  A check returns
+// what is wrong with the field, or "" if it is fine.  To check something
+// new, add a rule here
+var rules = []struct {
+	field string
+	check func(f Fields) string
+}{
+	{"id", func(f Fields) string {
+		if f.Id < 1 {
+			return "must be 1 or more"
+		}
+		return ""
+	}},
+	{"title", func(f Fields) string {
+		switch {
+		case strings.TrimSpace(f.Title) == "":
+			return "is required"
+		case utf8.RuneCountInString(f.Title) > MaxTitleLength:
+			return fmt.Sprintf("must be at most %d characters", MaxTitleLength)
+		case strings.IndexFunc(f.Title, unicode.IsControl) >= 0:
+			return "must not contain control characters"
+		}
+		return ""
+	}},
+}
+
+// Check runs every rule on the fields, all of the fields that are not
+// valid are returned in a *ValidationError
+func Check(f Fields) error {
+	var fields []FieldError
+	for _, rule := range rules {
+		if msg := rule.check(f); msg != "" {
+			fields = append(fields, FieldError{Field: rule.field, Message: msg})
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// Item is a todo item that can validate itself, usually by calling Check
+type Item interface {
+	Validate() error
+}
+
+// Decode reads a todo item from JSON and validates it.  It is strict about
+// what it accepts, anything the item does not have is rejected instead of
+// being ignored:
+//
+//   - more than MaxItemBytes is ErrItemTooLarge
+//   - anything but a single JSON object is ErrMalformedItem
+//   - unknown fields and fields of the wrong type are a *ValidationError,
+//     and so are values that the item's Validate rejects
+func Decode[T Item](r io.Reader) (T, error) {
+	var item, empty T
+
+	//read one byte more than the limit to know if it was gone over
+	body, err := io.ReadAll(io.LimitReader(r, MaxItemBytes+1))
+	if err != nil {
+		return empty, err
+	}
+	if len(body) > MaxItemBytes {
+		return empty, ErrItemTooLarge
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&item); err != nil {
+		return empty, decodeError(err)
+	}
+	if dec.More() {
+		return empty, fmt.Errorf("%w: there is more after the item", ErrMalformedItem)
+	}
+
+	if err := item.Validate(); err != nil {
+		return empty, err
+	}
+	return item, nil
+}
+
+// decodeError turns the errors from encoding/json into field errors where
+// it can.  Unknown fields don't have their own error type, so the field
+// name is taken from the message
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &ValidationError{Fields: []FieldError{
+			{Field: typeErr.Field, Message: "must be a " + jsonTypeName(typeErr.Type.Kind().String())},
+		}}
+	}
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &ValidationError{Fields: []FieldError{
+			{Field: strings.Trim(name, `"`), Message: "is not a todo item field"},
+		}}
+	}
+	return fmt.Errorf("%w: %v", ErrMalformedItem, err)
+}
+
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "bool":
+		return "boolean"
+	}
+	return kind
+}
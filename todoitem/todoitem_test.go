@@ -0,0 +1,105 @@
+package todoitem_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"drexel.edu/todoitem"
+	"github.com/stretchr/testify/assert"
+)
+
+// item is a todo item like the ones the API and the CLI have
+type item struct {
+	Id     int    `json:"id"`
+	Title  string `json:"title"`
+	IsDone bool   `json:"done"`
+	Owner  string `json:"owner"`
+}
+
+func (i item) Validate() error {
+	return todoitem.Check(todoitem.Fields{Id: i.Id, Title: i.Title})
+}
+
+func fieldErrors(t *testing.T, err error) []todoitem.FieldError {
+	var verr *todoitem.ValidationError
+	if !assert.True(t, errors.As(err, &verr), "not a *ValidationError: %v", err) {
+		return nil
+	}
+	return verr.Fields
+}
+
+func TestCheckValid(t *testing.T) {
+	assert.NoError(t, todoitem.Check(todoitem.Fields{Id: 1, Title: "Learn Go"}))
+	assert.NoError(t, todoitem.Check(todoitem.Fields{Id: 1, Title: strings.Repeat("é", todoitem.MaxTitleLength)}))
+}
+
+func TestCheckRules(t *testing.T) {
+	tests := map[todoitem.Fields]todoitem.FieldError{
+		{Id: 0, Title: "a"}:                  {Field: "id", Message: "must be 1 or more"},
+		{Id: -3, Title: "a"}:                 {Field: "id", Message: "must be 1 or more"},
+		{Id: 1, Title: ""}:                   {Field: "title", Message: "is required"},
+		{Id: 1, Title: "  \t "}:              {Field: "title", Message: "is required"},
+		{Id: 1, Title: "line one\nline two"}: {Field: "title", Message: "must not contain control characters"},
+		{Id: 1, Title: "bad\u0007title"}:     {Field: "title", Message: "must not contain control characters"},
+		{Id: 1, Title: strings.Repeat("é", todoitem.MaxTitleLength+1)}: {Field: "title", Message: "must be at most 200 characters"},
+	}
+	for f, want := range tests {
+		assert.Equal(t, []todoitem.FieldError{want}, fieldErrors(t, todoitem.Check(f)), "%+v", f)
+	}
+}
+
+func TestCheckReportsEveryField(t *testing.T) {
+	err := todoitem.Check(todoitem.Fields{Id: 0, Title: ""})
+	assert.Equal(t, []todoitem.FieldError{
+		{Field: "id", Message: "must be 1 or more"},
+		{Field: "title", Message: "is required"},
+	}, fieldErrors(t, err))
+	assert.EqualError(t, err, "invalid todo item: id must be 1 or more; title is required")
+}
+
+func TestDecodeValid(t *testing.T) {
+	got, err := todoitem.Decode[item](strings.NewReader(`{"id": 5, "title": "Learn Go", "done": true, "owner": "bob"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, item{Id: 5, Title: "Learn Go", IsDone: true, Owner: "bob"}, got)
+}
+
+func TestDecodeValidates(t *testing.T) {
+	_, err := todoitem.Decode[item](strings.NewReader(`{"id": -3, "title": "  "}`))
+	assert.Equal(t, []todoitem.FieldError{
+		{Field: "id", Message: "must be 1 or more"},
+		{Field: "title", Message: "is required"},
+	}, fieldErrors(t, err))
+}
+
+func TestDecodeFieldErrors(t *testing.T) {
+	tests := map[string]todoitem.FieldError{
+		`{"id": 1, "title": "x", "priority": 2}`: {Field: "priority", Message: "is not a todo item field"},
+		`{"id": "1", "title": "x"}`:              {Field: "id", Message: "must be a number"},
+		`{"id": 1, "title": "x", "done": "yes"}`: {Field: "done", Message: "must be a boolean"},
+		`{"id": 1, "title": "x", "owner": 7}`:    {Field: "owner", Message: "must be a string"},
+	}
+	for body, want := range tests {
+		_, err := todoitem.Decode[item](strings.NewReader(body))
+		assert.Equal(t, []todoitem.FieldError{want}, fieldErrors(t, err), body)
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, body := range []string{``, `not json`, `[]`, `{"id": 1,`, `{"id": 1, "title": "x"} {}`} {
+		_, err := todoitem.Decode[item](strings.NewReader(body))
+		assert.ErrorIs(t, err, todoitem.ErrMalformedItem, body)
+	}
+}
+
+func TestDecodeTooLarge(t *testing.T) {
+	body := `{"id": 1, "title": "x"}` + strings.Repeat(" ", todoitem.MaxItemBytes)
+	_, err := todoitem.Decode[item](strings.NewReader(body))
+	assert.ErrorIs(t, err, todoitem.ErrItemTooLarge)
+
+	//exactly the limit is still read
+	body = `{"id": 1, "title": "x"}`
+	body += strings.Repeat(" ", todoitem.MaxItemBytes-len(body))
+	_, err = todoitem.Decode[item](strings.NewReader(body))
+	assert.NoError(t, err)
+}
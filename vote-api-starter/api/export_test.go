@@ -0,0 +1,9 @@
+package api
+
+// Watchers is how many results streams are watching a poll, the tests use
+// it to check that a stream is dropped when its client goes away
+func (p *PollApi) Watchers(pollID uint) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.watchers[pollID])
+}
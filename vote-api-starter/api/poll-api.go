@@ -0,0 +1,287 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	"voter-api-starter/poll"
+	"voter-api-starter/voter"
+	election "voter-api-starter/votes"
+)
+
+type PollApi struct {
+	lock      sync.Mutex //the scheduler closes polls from its own goroutine
+	pollList  poll.PollList
+	voteData  election.VoteData
+	finalData map[uint]election.PollResults //tally snapshots of closed polls
+	scheduler *PollScheduler
+	voters    *VoterApi //optional, used to record who took part in a poll
+
+	//who took part in each poll when there is no voter api, poll id to
+	//voter ids
+	participants map[uint]map[uint]bool
+
+	//results streams waiting for updates, see results-stream.go
+	watchers       map[uint]map[chan struct{}]bool
+	updateInterval time.Duration
+}
+
+func NewPollApi() *PollApi {
+	return &PollApi{
+		pollList: poll.PollList{
+			Polls: make(map[uint]poll.Poll),
+		},
+		voteData: election.VoteData{
+			Votes: []election.Vote{},
+		},
+		finalData:      make(map[uint]election.PollResults),
+		participants:   make(map[uint]map[uint]bool),
+		watchers:       make(map[uint]map[chan struct{}]bool),
+		updateInterval: DefaultResultsUpdateInterval,
+	}
+}
+
+// ConnectVoterApi links the poll api to the voter api, once connected
+// every vote is also recorded in the voter's VoteHistory, which is what
+// stops a voter from voting twice in the same poll.  Without it the poll
+// api keeps its own list of who voted in each poll
+func (p *PollApi) ConnectVoterApi(voters *VoterApi) {
+	p.voters = voters
+}
+
+// AddPoll adds a new poll, a poll id that is already in use is rejected
+// since the votes and the closing snapshot of the old poll are kept under
+// its id
+func (p *PollApi) AddPoll(newPoll *poll.Poll) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.pollList.Polls[newPoll.PollID]; ok {
+		return fmt.Errorf("poll %d already exists", newPoll.PollID)
+	}
+	p.pollList.Polls[newPoll.PollID] = *newPoll
+	return nil
+}
+
+func (p *PollApi) GetPoll(pollID uint) (poll.Poll, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.getPoll(pollID)
+}
+
+func (p *PollApi) getPoll(pollID uint) (poll.Poll, error) {
+	pl, ok := p.pollList.Polls[pollID]
+	if !ok {
+		return poll.Poll{}, fmt.Errorf("poll %d does not exist", pollID)
+	}
+	return pl, nil
+}
+
+// AddVote is what a POST /polls/:id/votes would call, the option ids are
+// in the order the voter provided them, which matters for ranked polls.
+// Votes cast outside of the poll's window return poll.ErrPollNotOpen or
+// poll.ErrPollClosed, which the handler should turn into a 409
+func (p *PollApi) AddVote(voteID, pollID, voterID uint, optionIDs []uint) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pl, err := p.checkBallot(pollID, optionIDs)
+	if err != nil {
+		return err
+	}
+	if pl.IsAnonymous {
+		return fmt.Errorf("poll %d uses anonymous ballots, use CastAnonymousBallot", pollID)
+	}
+
+	for _, v := range p.voteData.Votes {
+		if v.BallotID == "" && v.VoteID == voteID {
+			return errors.New("vote already exists")
+		}
+	}
+
+	if err := p.recordParticipation(voterID, pollID); err != nil {
+		return err
+	}
+
+	p.voteData.Votes = append(p.voteData.Votes, *election.NewVote(pollID, voteID, voterID, optionIDs...))
+	p.notifyWatchers(pollID)
+	return nil
+}
+
+// CastAnonymousBallot is what a POST /polls/:id/votes would call for an
+// anonymous poll.  The voter's participation is recorded, but the ballot is
+// stored without the voter id, under a random ballot id, and at a random
+// position so the order of the votes can't be matched to the VoteHistory.
+// The returned receipt can be given to VerifyReceipt later on
+func (p *PollApi) CastAnonymousBallot(pollID, voterID uint, optionIDs []uint) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pl, err := p.checkBallot(pollID, optionIDs)
+	if err != nil {
+		return "", err
+	}
+	if !pl.IsAnonymous {
+		return "", fmt.Errorf("poll %d does not use anonymous ballots", pollID)
+	}
+
+	ballot, receipt, err := election.NewAnonymousBallot(pollID, optionIDs...)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.recordParticipation(voterID, pollID); err != nil {
+		return "", err
+	}
+
+	votes := append(p.voteData.Votes, election.Vote{})
+	pos := rand.Intn(len(votes))
+	copy(votes[pos+1:], votes[pos:])
+	votes[pos] = *ballot
+	p.voteData.Votes = votes
+	p.notifyWatchers(pollID)
+
+	return receipt, nil
+}
+
+// VerifyReceipt reports if the ballot that was issued with the receipt
+// was counted in the poll, without revealing how the ballot was cast
+func (p *PollApi) VerifyReceipt(pollID uint, receipt string) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, err := p.getPoll(pollID); err != nil {
+		return false, err
+	}
+
+	hash := election.HashReceipt(receipt)
+	for _, v := range p.voteData.Votes {
+		if v.PollID == pollID && v.ReceiptHash == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkBallot makes sure that the poll exists, is open, and that the
+// option ids are a valid answer for it
+func (p *PollApi) checkBallot(pollID uint, optionIDs []uint) (poll.Poll, error) {
+	pl, err := p.getPoll(pollID)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+
+	if err := pl.CheckOpen(time.Now()); err != nil {
+		return poll.Poll{}, err
+	}
+
+	if err := pl.ValidateSelection(optionIDs); err != nil {
+		return poll.Poll{}, err
+	}
+	return pl, nil
+}
+
+// recordParticipation notes that the voter took part in the poll, it
+// returns voter.ErrAlreadyVoted if they already did.  Anonymous ballots
+// don't keep the voter id, so this is the only one vote per voter check
+// they get
+func (p *PollApi) recordParticipation(voterID, pollID uint) error {
+	if p.voters != nil {
+		return p.voters.AddPoll(voterID, pollID)
+	}
+
+	if p.participants[pollID] == nil {
+		p.participants[pollID] = make(map[uint]bool)
+	}
+	if p.participants[pollID][voterID] {
+		return voter.ErrAlreadyVoted
+	}
+	p.participants[pollID][voterID] = true
+	return nil
+}
+
+// ClosePoll is what a POST /polls/:id/close would call.  It stops the poll
+// from accepting votes and saves the final tally so it can no longer change
+func (p *PollApi) ClosePoll(pollID uint) (election.PollResults, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	pl, err := p.getPoll(pollID)
+	if err != nil {
+		return election.PollResults{}, err
+	}
+	if pl.IsClosed {
+		return election.PollResults{}, poll.ErrPollClosed
+	}
+
+	return p.closePoll(pl, time.Now()), nil
+}
+
+func (p *PollApi) closePoll(pl poll.Poll, t time.Time) election.PollResults {
+	pl.Close(t)
+	p.pollList.Polls[pl.PollID] = pl
+
+	results := election.Tally(&pl, p.voteData.Votes)
+	p.finalData[pl.PollID] = results
+	p.notifyWatchers(pl.PollID)
+	return results
+}
+
+// closeExpiredPolls closes every poll whose closing time is before t, it
+// returns the ids of the polls that were closed
+func (p *PollApi) closeExpiredPolls(t time.Time) []uint {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	closed := []uint{}
+	for _, pl := range p.pollList.Polls {
+		if pl.ShouldClose(t) {
+			p.closePoll(pl, pl.ClosesAt)
+			closed = append(closed, pl.PollID)
+		}
+	}
+	return closed
+}
+
+// GetPollResults is what a GET /polls/:id/results would call, closed polls
+// always return the snapshot that was taken when they were closed
+func (p *PollApi) GetPollResults(pollID uint) (election.PollResults, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if results, ok := p.finalData[pollID]; ok {
+		return results, nil
+	}
+
+	pl, err := p.getPoll(pollID)
+	if err != nil {
+		return election.PollResults{}, err
+	}
+	return election.Tally(&pl, p.voteData.Votes), nil
+}
+
+func (p *PollApi) GetPollResultsJson(pollID uint) string {
+	results, err := p.GetPollResults(pollID)
+	if err != nil {
+		b, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return string(b)
+	}
+	return results.ToJson()
+}
+
+// StartScheduler starts a background goroutine that checks every interval
+// for polls that have reached their closing time and closes them
+func (p *PollApi) StartScheduler(interval time.Duration) {
+	if p.scheduler == nil {
+		p.scheduler = NewPollScheduler(p, interval)
+	}
+	p.scheduler.Start()
+}
+
+func (p *PollApi) StopScheduler() {
+	if p.scheduler != nil {
+		p.scheduler.Stop()
+	}
+}
@@ -0,0 +1,59 @@
+package api_test
+
+import (
+	"testing"
+
+	"voter-api-starter/api"
+	"voter-api-starter/poll"
+	"voter-api-starter/voter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func anonymousPoll() *poll.Poll {
+	p := poll.NewPoll(1, "Lunch", "Where?")
+	p.IsAnonymous = true
+	p.AddOption(1, "Tacos")
+	p.AddOption(2, "Pizza")
+	return p
+}
+
+func TestAddPollRejectsUsedID(t *testing.T) {
+	polls := api.NewPollApi()
+	require.NoError(t, polls.AddPoll(poll.NewSamplePoll()))
+	assert.Error(t, polls.AddPoll(poll.NewSamplePoll()))
+}
+
+func TestOneAnonymousBallotPerVoter(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect bool
+	}{
+		{"without a voter api", false},
+		{"with a voter api", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			polls := api.NewPollApi()
+			if tc.connect {
+				voters := api.NewVoterApi()
+				require.NoError(t, voters.AddVoter(7, "Ada", "Lovelace"))
+				require.NoError(t, voters.AddVoter(8, "Alan", "Turing"))
+				polls.ConnectVoterApi(voters)
+			}
+			require.NoError(t, polls.AddPoll(anonymousPoll()))
+
+			_, err := polls.CastAnonymousBallot(1, 7, []uint{1})
+			require.NoError(t, err)
+			_, err = polls.CastAnonymousBallot(1, 7, []uint{2})
+			assert.ErrorIs(t, err, voter.ErrAlreadyVoted)
+			_, err = polls.CastAnonymousBallot(1, 8, []uint{2})
+			assert.NoError(t, err)
+
+			results, err := polls.GetPollResults(1)
+			require.NoError(t, err)
+			assert.Equal(t, uint(2), results.TotalVotes)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PollScheduler periodically closes polls whose voting window has ended.
+// It follows the same start/stop pattern as the event manager in the
+// todo-api-w-events demo, a context is used to stop the goroutine
+type PollScheduler struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	polls    *PollApi
+	interval time.Duration
+	isActive bool
+}
+
+func NewPollScheduler(polls *PollApi, interval time.Duration) *PollScheduler {
+	return &PollScheduler{
+		ctx:      nil,
+		cancel:   nil,
+		polls:    polls,
+		interval: interval,
+		isActive: false,
+	}
+}
+
+func (ps *PollScheduler) Start() {
+	if !ps.isActive {
+		ps.ctx, ps.cancel = context.WithCancel(context.Background())
+		ps.isActive = true
+		go ps.scheduleLoop(ps.ctx)
+	}
+}
+
+func (ps *PollScheduler) scheduleLoop(ctx context.Context) {
+	ticker := time.NewTicker(ps.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping Poll Scheduler...")
+			return
+		case now := <-ticker.C:
+			for _, id := range ps.polls.closeExpiredPolls(now) {
+				log.Printf("Poll %d closed by scheduler", id)
+			}
+		}
+	}
+}
+
+func (ps *PollScheduler) Stop() {
+	if ps.isActive {
+		ps.cancel()
+		ps.isActive = false
+	}
+}
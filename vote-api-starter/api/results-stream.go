@@ -0,0 +1,141 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultResultsUpdateInterval is the fastest rate that a results stream
+// will push new tallies to a client, a busy poll can get many votes a
+// second and there is no point redrawing a results board that often
+const DefaultResultsUpdateInterval = time.Second
+
+// SetResultsUpdateInterval changes how often a results stream can send
+// updates, votes that land in between updates are combined into one update
+func (p *PollApi) SetResultsUpdateInterval(interval time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.updateInterval = interval
+}
+
+// subscribe returns a channel that gets a signal whenever the results of
+// the poll change.  The channel has a buffer of 1 so that signals are
+// combined if the subscriber is busy
+func (p *PollApi) subscribe(pollID uint) chan struct{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	ch := make(chan struct{}, 1)
+	if p.watchers[pollID] == nil {
+		p.watchers[pollID] = make(map[chan struct{}]bool)
+	}
+	p.watchers[pollID][ch] = true
+	return ch
+}
+
+func (p *PollApi) unsubscribe(pollID uint, ch chan struct{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.watchers[pollID], ch)
+	if len(p.watchers[pollID]) == 0 {
+		delete(p.watchers, pollID)
+	}
+}
+
+// notifyWatchers must be called with the lock held
+func (p *PollApi) notifyWatchers(pollID uint) {
+	for ch := range p.watchers[pollID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+			//there is already an update waiting to be sent
+		}
+	}
+}
+
+// StreamResults is the handler for GET /polls/:id/results/stream.  It uses
+// Server-Sent Events to send the current tally right away, and then again
+// every time a vote lands, but never more often than the update interval.
+// When the poll closes the final tally is sent followed by a closed event
+// and the stream ends.  With gin you can call this from a handler with
+// p.StreamResults(c.Writer, c.Request, pollID)
+func (p *PollApi) StreamResults(w http.ResponseWriter, r *http.Request, pollID uint) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := p.GetPoll(pollID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	updates := p.subscribe(pollID)
+	defer p.unsubscribe(pollID, updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	p.lock.Lock()
+	interval := p.updateInterval
+	p.lock.Unlock()
+
+	var lastSent time.Time
+	var pending <-chan time.Time
+
+	//send writes the current results to the client, it returns false once
+	//the stream should end
+	send := func() bool {
+		lastSent = time.Now()
+		results, err := p.GetPollResults(pollID)
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+			flusher.Flush()
+			return false
+		}
+		fmt.Fprintf(w, "event: results\ndata: %s\n\n", results.ToJson())
+
+		pl, _ := p.GetPoll(pollID)
+		if pl.IsClosed {
+			fmt.Fprintf(w, "event: closed\ndata: {\"PollID\":%d}\n\n", pollID)
+			flusher.Flush()
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send() {
+		return
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-updates:
+			if pending != nil {
+				//an update is already scheduled, it will include this vote
+				continue
+			}
+			wait := interval - time.Since(lastSent)
+			if wait <= 0 {
+				if !send() {
+					return
+				}
+				continue
+			}
+			pending = time.After(wait)
+		case <-pending:
+			pending = nil
+			if !send() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"voter-api-starter/api"
+	election "voter-api-starter/votes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nextResults reads the stream up to the next results event
+func nextResults(t *testing.T, events *bufio.Scanner) election.PollResults {
+	var event string
+	for events.Scan() {
+		line := events.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			continue
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && event == "results" {
+			var results election.PollResults
+			require.NoError(t, json.Unmarshal([]byte(data), &results))
+			return results
+		}
+	}
+	require.FailNow(t, "the stream ended without results", events.Err())
+	return election.PollResults{}
+}
+
+func TestStreamResults(t *testing.T) {
+	polls := api.NewPollApi()
+	polls.SetResultsUpdateInterval(0)
+	require.NoError(t, polls.AddPoll(anonymousPoll()))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls.StreamResults(w, r, 1)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	events := bufio.NewScanner(resp.Body)
+
+	//the tally is sent as soon as the stream starts, and then again for
+	//the vote
+	assert.Equal(t, uint(0), nextResults(t, events).TotalVotes)
+	assert.Equal(t, 1, polls.Watchers(1))
+
+	_, err = polls.CastAnonymousBallot(1, 7, []uint{2})
+	require.NoError(t, err)
+	results := nextResults(t, events)
+	assert.Equal(t, uint(1), results.TotalVotes)
+	assert.Equal(t, uint(1), results.Counts[2])
+
+	//once the client goes away the stream stops watching the poll
+	cancel()
+	assert.Eventually(t, func() bool { return polls.Watchers(1) == 0 }, time.Second, 10*time.Millisecond)
+}
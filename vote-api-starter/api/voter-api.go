@@ -6,6 +6,10 @@ import (
 	"voter-api-starter/voter"
 )
 
+// DefaultHistoryPageSize is used for GET /voters/:id/history when the
+// caller does not provide a page size
+const DefaultHistoryPageSize = 10
+
 type VoterApi struct {
 	voterList voter.VoterList
 }
@@ -18,14 +22,31 @@ func NewVoterApi() *VoterApi {
 	}
 }
 
-func (v *VoterApi) AddVoter(voterID uint, firstName, lastName string) {
-	v.voterList.Voters[voterID] = *voter.NewVoter(voterID, firstName, lastName)
+func (v *VoterApi) AddVoter(voterID uint, firstName, lastName string) error {
+	if _, ok := v.voterList.Voters[voterID]; ok {
+		return fmt.Errorf("voter %d already exists", voterID)
+	}
+
+	newVoter, err := voter.NewVoter(voterID, firstName, lastName)
+	if err != nil {
+		return err
+	}
+	v.voterList.Voters[voterID] = *newVoter
+	return nil
 }
 
-func (v *VoterApi) AddPoll(voterID, pollID uint) {
-	voter := v.voterList.Voters[voterID]
-	voter.AddPoll(pollID)
+// AddPoll records that a voter took part in a poll, it returns
+// voter.ErrAlreadyVoted if the voter already has the poll in their history
+func (v *VoterApi) AddPoll(voterID, pollID uint) error {
+	voter, ok := v.voterList.Voters[voterID]
+	if !ok {
+		return fmt.Errorf("voter %d does not exist", voterID)
+	}
+	if err := voter.AddPoll(pollID); err != nil {
+		return err
+	}
 	v.voterList.Voters[voterID] = voter
+	return nil
 }
 
 func (v *VoterApi) GetVoter(voterID uint) voter.Voter {
@@ -38,6 +59,19 @@ func (v *VoterApi) GetVoterJson(voterID uint) string {
 	return voter.ToJson()
 }
 
+// GetVoterHistory is what GET /voters/:id/history?page=&size= would call,
+// a pageSize of 0 uses DefaultHistoryPageSize
+func (v *VoterApi) GetVoterHistory(voterID uint, page, pageSize int) (voter.VoteHistoryPage, error) {
+	if pageSize == 0 {
+		pageSize = DefaultHistoryPageSize
+	}
+	vtr, ok := v.voterList.Voters[voterID]
+	if !ok {
+		return voter.VoteHistoryPage{}, fmt.Errorf("voter %d does not exist", voterID)
+	}
+	return vtr.GetHistoryPage(page, pageSize)
+}
+
 func (v *VoterApi) GetVoterList() voter.VoterList {
 	return v.voterList
 }
@@ -68,12 +102,20 @@ func (v *VoterApi) LetsSimulateAPostForAPoll(pollID uint) {
 	//2. Add the poll to the voter
 
 	//3. Look up the voter from the voter database being managed by the API
-	voter := v.voterList.Voters[voterNewPoll.VoterID]
+	voter, ok := v.voterList.Voters[voterNewPoll.VoterID]
+	if !ok {
+		fmt.Println("voter not found")
+		return
+	}
 	fmt.Println(voter)
 
 	//4 Add the poll from the body to the voter, using the receiver in the voter
-	voter.AddPollWithTimeDetails(voterNewPoll.VoteHistory[0].PollID,
-		voterNewPoll.VoteHistory[0].VoteDate)
+	//  a voter can only vote once per poll, in gin this would be a 409
+	if err := voter.AddPollWithTimeDetails(voterNewPoll.VoteHistory[0].PollID,
+		voterNewPoll.VoteHistory[0].VoteDate); err != nil {
+		fmt.Println(err)
+		return
+	}
 	v.voterList.Voters[voterNewPoll.VoterID] = voter
 	fmt.Println("------------------------")
 	fmt.Println(voter)
@@ -2,6 +2,25 @@ package poll
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PollType describes how a voter is allowed to answer a poll
+type PollType int
+
+const (
+	SingleChoice PollType = iota //exactly one option
+	MultiSelect                  //one or more options, up to MaxSelections
+	RankedChoice                 //an ordered list of options, most preferred first
+)
+
+// Errors returned when a vote is cast outside of a poll's voting window, an
+// API handler should report both of these as a 409 Conflict
+var (
+	ErrPollNotOpen = errors.New("poll is not open for voting yet")
+	ErrPollClosed  = errors.New("poll is closed")
 )
 
 type pollOption struct {
@@ -10,10 +29,17 @@ type pollOption struct {
 }
 
 type Poll struct {
-	PollID       uint
-	PollTitle    string
-	PollQuestion string
-	PollOptions  []pollOption
+	PollID        uint
+	PollTitle     string
+	PollQuestion  string
+	PollType      PollType
+	MaxSelections uint //only used by MultiSelect polls, 0 means no limit
+	PollOptions   []pollOption
+	OpensAt       time.Time //zero value means the poll opens immediately
+	ClosesAt      time.Time //zero value means the poll stays open until closed
+	IsClosed      bool
+	ClosedAt      time.Time
+	IsAnonymous   bool //ballots are stored without the voter id
 }
 type PollList struct {
 	Polls map[uint]Poll //A map of VoterIDs as keys and Voter structs as values
@@ -21,11 +47,19 @@ type PollList struct {
 
 // constructor for VoterList struct
 func NewPoll(id uint, title, question string) *Poll {
+	return NewPollWithType(id, title, question, SingleChoice, 0)
+}
+
+// constructor for a poll that is not a simple single choice poll, for
+// MultiSelect polls maxSelections caps how many options a voter may pick
+func NewPollWithType(id uint, title, question string, pt PollType, maxSelections uint) *Poll {
 	return &Poll{
-		PollID:       id,
-		PollTitle:    title,
-		PollQuestion: question,
-		PollOptions:  []pollOption{},
+		PollID:        id,
+		PollTitle:     title,
+		PollQuestion:  question,
+		PollType:      pt,
+		MaxSelections: maxSelections,
+		PollOptions:   []pollOption{},
 	}
 }
 
@@ -34,6 +68,7 @@ func NewSamplePoll() *Poll {
 		PollID:       1,
 		PollTitle:    "Favorite Pet",
 		PollQuestion: "What type of pet do you like best?",
+		PollType:     SingleChoice,
 		PollOptions: []pollOption{
 			{PollOptionID: 1, PollOptionValue: "Dog"},
 			{PollOptionID: 2, PollOptionValue: "Cat"},
@@ -44,6 +79,102 @@ func NewSamplePoll() *Poll {
 	}
 }
 
+// SetWindow sets when the poll accepts votes, either time can be left
+// as the zero value to leave that side of the window open
+func (p *Poll) SetWindow(opensAt, closesAt time.Time) error {
+	if !opensAt.IsZero() && !closesAt.IsZero() && !closesAt.After(opensAt) {
+		return errors.New("a poll must close after it opens")
+	}
+	p.OpensAt = opensAt
+	p.ClosesAt = closesAt
+	return nil
+}
+
+// CheckOpen returns nil if the poll is accepting votes at time t
+func (p *Poll) CheckOpen(t time.Time) error {
+	if p.IsClosed {
+		return ErrPollClosed
+	}
+	if !p.OpensAt.IsZero() && t.Before(p.OpensAt) {
+		return ErrPollNotOpen
+	}
+	if !p.ClosesAt.IsZero() && !t.Before(p.ClosesAt) {
+		return ErrPollClosed
+	}
+	return nil
+}
+
+// ShouldClose reports if the poll is still marked open but its
+// closing time has passed
+func (p *Poll) ShouldClose(t time.Time) bool {
+	return !p.IsClosed && !p.ClosesAt.IsZero() && !t.Before(p.ClosesAt)
+}
+
+func (p *Poll) Close(t time.Time) {
+	p.IsClosed = true
+	p.ClosedAt = t
+}
+
+func (p *Poll) AddOption(optionID uint, value string) {
+	p.PollOptions = append(p.PollOptions, pollOption{PollOptionID: optionID, PollOptionValue: value})
+}
+
+func (p *Poll) HasOption(optionID uint) bool {
+	for _, o := range p.PollOptions {
+		if o.PollOptionID == optionID {
+			return true
+		}
+	}
+	return false
+}
+
+// OptionIDs returns the ids of all of the options in the order they
+// were added to the poll
+func (p *Poll) OptionIDs() []uint {
+	ids := make([]uint, 0, len(p.PollOptions))
+	for _, o := range p.PollOptions {
+		ids = append(ids, o.PollOptionID)
+	}
+	return ids
+}
+
+// ValidateSelection checks that an ordered list of option ids is an
+// acceptable answer for this poll given its type
+func (p *Poll) ValidateSelection(optionIDs []uint) error {
+	if len(optionIDs) == 0 {
+		return errors.New("a vote must select at least one option")
+	}
+
+	seen := make(map[uint]bool)
+	for _, id := range optionIDs {
+		if !p.HasOption(id) {
+			return fmt.Errorf("option %d is not part of poll %d", id, p.PollID)
+		}
+		if seen[id] {
+			return fmt.Errorf("option %d was selected more than once", id)
+		}
+		seen[id] = true
+	}
+
+	switch p.PollType {
+	case SingleChoice:
+		if len(optionIDs) != 1 {
+			return errors.New("single choice polls accept exactly one option")
+		}
+	case MultiSelect:
+		if p.MaxSelections > 0 && uint(len(optionIDs)) > p.MaxSelections {
+			return fmt.Errorf("this poll accepts at most %d options", p.MaxSelections)
+		}
+	case RankedChoice:
+		//any non-empty ranking of distinct options is fine, voters do not
+		//have to rank every option
+	default:
+		return fmt.Errorf("unknown poll type %d", p.PollType)
+	}
+
+	return nil
+}
+
 func (p *Poll) ToJson() string {
 	b, _ := json.Marshal(p)
 	return string(b)
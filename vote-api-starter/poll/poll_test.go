@@ -0,0 +1,110 @@
+package poll_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"voter-api-starter/poll"
+)
+
+var (
+	opens  = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	closes = time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+)
+
+func TestSetWindow(t *testing.T) {
+	p := poll.NewPoll(1, "Lunch", "Where?")
+
+	assert.Error(t, p.SetWindow(closes, opens))
+	assert.Error(t, p.SetWindow(opens, opens))
+	assert.NoError(t, p.SetWindow(opens, closes))
+	assert.NoError(t, p.SetWindow(time.Time{}, closes))
+	assert.NoError(t, p.SetWindow(opens, time.Time{}))
+}
+
+func TestCheckOpen(t *testing.T) {
+	tests := []struct {
+		name            string
+		opensAt, closes time.Time
+		closed          bool
+		at              time.Time
+		want            error
+	}{
+		{"no window", time.Time{}, time.Time{}, false, opens, nil},
+		{"before it opens", opens, closes, false, opens.Add(-time.Minute), poll.ErrPollNotOpen},
+		{"when it opens", opens, closes, false, opens, nil},
+		{"during the window", opens, closes, false, opens.Add(time.Hour), nil},
+		{"when it closes", opens, closes, false, closes, poll.ErrPollClosed},
+		{"after it closes", opens, closes, false, closes.Add(time.Minute), poll.ErrPollClosed},
+		{"only a closing time", time.Time{}, closes, false, opens, nil},
+		{"closed by hand", opens, closes, true, opens.Add(time.Hour), poll.ErrPollClosed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := poll.NewPoll(1, "Lunch", "Where?")
+			assert.NoError(t, p.SetWindow(tc.opensAt, tc.closes))
+			if tc.closed {
+				p.Close(tc.at)
+			}
+			assert.Equal(t, tc.want, p.CheckOpen(tc.at))
+		})
+	}
+}
+
+func TestShouldClose(t *testing.T) {
+	p := poll.NewPoll(1, "Lunch", "Where?")
+	assert.False(t, p.ShouldClose(closes), "no closing time")
+
+	assert.NoError(t, p.SetWindow(opens, closes))
+	assert.False(t, p.ShouldClose(closes.Add(-time.Second)))
+	assert.True(t, p.ShouldClose(closes))
+
+	p.Close(closes)
+	assert.False(t, p.ShouldClose(closes.Add(time.Hour)), "already closed")
+	assert.Equal(t, closes, p.ClosedAt)
+}
+
+func TestValidateSelection(t *testing.T) {
+	tests := []struct {
+		name      string
+		pollType  poll.PollType
+		max       uint
+		selection []uint
+		ok        bool
+	}{
+		{"single choice", poll.SingleChoice, 0, []uint{2}, true},
+		{"single choice with two", poll.SingleChoice, 0, []uint{1, 2}, false},
+		{"nothing selected", poll.SingleChoice, 0, []uint{}, false},
+		{"unknown option", poll.SingleChoice, 0, []uint{9}, false},
+		{"multi select", poll.MultiSelect, 2, []uint{1, 3}, true},
+		{"multi select over the max", poll.MultiSelect, 2, []uint{1, 2, 3}, false},
+		{"multi select without a max", poll.MultiSelect, 0, []uint{1, 2, 3}, true},
+		{"multi select repeats", poll.MultiSelect, 0, []uint{1, 1}, false},
+		{"ranked part of the options", poll.RankedChoice, 0, []uint{3, 1}, true},
+		{"ranked repeats", poll.RankedChoice, 0, []uint{3, 1, 3}, false},
+		{"unknown poll type", poll.PollType(9), 0, []uint{1}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := poll.NewPollWithType(1, "Lunch", "Where?", tc.pollType, tc.max)
+			p.AddOption(1, "Tacos")
+			p.AddOption(2, "Pizza")
+			p.AddOption(3, "Salad")
+
+			err := p.ValidateSelection(tc.selection)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestOptionIDs(t *testing.T) {
+	p := poll.NewSamplePoll()
+	assert.Equal(t, []uint{1, 2, 3, 4, 5}, p.OptionIDs())
+	assert.True(t, p.HasOption(5))
+	assert.False(t, p.HasOption(6))
+}
@@ -2,9 +2,16 @@ package voter
 
 import (
 	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
 	"time"
 )
 
+// ErrAlreadyVoted is returned when a poll is added to a voter's history
+// more than once, a voter only gets one vote per poll
+var ErrAlreadyVoted = errors.New("voter has already voted in this poll")
+
 type voterPoll struct {
 	PollID   uint
 	VoteDate time.Time
@@ -20,13 +27,27 @@ type VoterList struct {
 	Voters map[uint]Voter //A map of VoterIDs as keys and Voter structs as values
 }
 
+// VoteHistoryPage is one page of a voter's history, sorted by VoteDate
+type VoteHistoryPage struct {
+	VoterID     uint
+	Page        int
+	PageSize    int
+	Total       int
+	VoteHistory []voterPoll
+}
+
 // constructor for VoterList struct
-func NewVoter(id uint, fn, ln string) *Voter {
-	return &Voter{
-		FirstName:   fn,
-		LastName:    ln,
+func NewVoter(id uint, fn, ln string) (*Voter, error) {
+	v := &Voter{
+		VoterID:     id,
+		FirstName:   strings.TrimSpace(fn),
+		LastName:    strings.TrimSpace(ln),
 		VoteHistory: []voterPoll{},
 	}
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
 func NewSampleVoter() *Voter {
@@ -40,12 +61,68 @@ func NewSampleVoter() *Voter {
 	}
 }
 
-func (v *Voter) AddPoll(pollID uint) {
-	v.VoteHistory = append(v.VoteHistory, voterPoll{PollID: pollID, VoteDate: time.Now()})
+// Validate checks the fields that every voter must have
+func (v *Voter) Validate() error {
+	if v.FirstName == "" {
+		return errors.New("voter first name is required")
+	}
+	if v.LastName == "" {
+		return errors.New("voter last name is required")
+	}
+	return nil
+}
+
+func (v *Voter) HasVoted(pollID uint) bool {
+	for _, vp := range v.VoteHistory {
+		if vp.PollID == pollID {
+			return true
+		}
+	}
+	return false
 }
 
-func (v *Voter) AddPollWithTimeDetails(pollID uint, timeOfPoll time.Time) {
+func (v *Voter) AddPoll(pollID uint) error {
+	return v.AddPollWithTimeDetails(pollID, time.Now())
+}
+
+func (v *Voter) AddPollWithTimeDetails(pollID uint, timeOfPoll time.Time) error {
+	if v.HasVoted(pollID) {
+		return ErrAlreadyVoted
+	}
 	v.VoteHistory = append(v.VoteHistory, voterPoll{PollID: pollID, VoteDate: timeOfPoll})
+	return nil
+}
+
+// GetHistoryPage returns the voter's history sorted by VoteDate, oldest
+// first.  Pages start at 1, asking for a page past the end returns an
+// empty page rather than an error
+func (v *Voter) GetHistoryPage(page, pageSize int) (VoteHistoryPage, error) {
+	if page < 1 || pageSize < 1 {
+		return VoteHistoryPage{}, errors.New("page and page size must be greater than 0")
+	}
+
+	history := make([]voterPoll, len(v.VoteHistory))
+	copy(history, v.VoteHistory)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].VoteDate.Before(history[j].VoteDate)
+	})
+
+	start := (page - 1) * pageSize
+	if start > len(history) {
+		start = len(history)
+	}
+	end := start + pageSize
+	if end > len(history) {
+		end = len(history)
+	}
+
+	return VoteHistoryPage{
+		VoterID:     v.VoterID,
+		Page:        page,
+		PageSize:    pageSize,
+		Total:       len(history),
+		VoteHistory: history[start:end],
+	}, nil
 }
 
 func (v *Voter) ToJson() string {
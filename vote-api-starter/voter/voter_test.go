@@ -0,0 +1,86 @@
+package voter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"voter-api-starter/voter"
+)
+
+func TestNewVoter(t *testing.T) {
+	v, err := voter.NewVoter(1, "  Ada ", "Lovelace ")
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", v.FirstName)
+	assert.Equal(t, "Lovelace", v.LastName)
+	assert.Empty(t, v.VoteHistory)
+
+	_, err = voter.NewVoter(2, " ", "Lovelace")
+	assert.Error(t, err)
+	_, err = voter.NewVoter(3, "Ada", "")
+	assert.Error(t, err)
+}
+
+func TestAddPollOnlyOnce(t *testing.T) {
+	v, err := voter.NewVoter(1, "Ada", "Lovelace")
+	require.NoError(t, err)
+
+	assert.False(t, v.HasVoted(4))
+	assert.NoError(t, v.AddPoll(4))
+	assert.True(t, v.HasVoted(4))
+	assert.ErrorIs(t, v.AddPoll(4), voter.ErrAlreadyVoted)
+	assert.Len(t, v.VoteHistory, 1)
+}
+
+func TestGetHistoryPage(t *testing.T) {
+	v, err := voter.NewVoter(1, "Ada", "Lovelace")
+	require.NoError(t, err)
+
+	//added out of order, pages are sorted by date
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range []struct {
+		poll uint
+		day  int
+	}{{3, 3}, {1, 1}, {5, 5}, {2, 2}, {4, 4}} {
+		require.NoError(t, v.AddPollWithTimeDetails(p.poll, start.AddDate(0, 0, p.day)))
+	}
+
+	tests := []struct {
+		name           string
+		page, pageSize int
+		polls          []uint
+	}{
+		{"first page", 1, 2, []uint{1, 2}},
+		{"middle page", 2, 2, []uint{3, 4}},
+		{"last page is short", 3, 2, []uint{5}},
+		{"past the end", 4, 2, []uint{}},
+		{"everything", 1, 10, []uint{1, 2, 3, 4, 5}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := v.GetHistoryPage(tc.page, tc.pageSize)
+			require.NoError(t, err)
+
+			polls := []uint{}
+			for _, vp := range page.VoteHistory {
+				polls = append(polls, vp.PollID)
+			}
+			assert.Equal(t, tc.polls, polls)
+			assert.Equal(t, 5, page.Total)
+			assert.Equal(t, tc.page, page.Page)
+			assert.Equal(t, tc.pageSize, page.PageSize)
+		})
+	}
+
+	//the voter's own history is not sorted by paging
+	assert.Equal(t, uint(3), v.VoteHistory[0].PollID)
+}
+
+func TestGetHistoryPageBadArgs(t *testing.T) {
+	v := voter.NewSampleVoter()
+	for _, args := range [][2]int{{0, 10}, {1, 0}, {-1, 5}} {
+		_, err := v.GetHistoryPage(args[0], args[1])
+		assert.Error(t, err, "page %d size %d", args[0], args[1])
+	}
+}
@@ -0,0 +1,187 @@
+package election
+
+import (
+	"encoding/json"
+	"sort"
+
+	"voter-api-starter/poll"
+)
+
+// Round captures one round of an instant-runoff count.  Counts holds
+// the number of ballots currently counting towards each option that is
+// still in the race, Exhausted is the number of ballots that no longer
+// rank any remaining option
+type Round struct {
+	Round      int
+	Counts     map[uint]uint
+	Exhausted  uint
+	Eliminated []uint
+}
+
+type PollResults struct {
+	PollID     uint
+	PollType   poll.PollType
+	TotalVotes uint
+	Counts     map[uint]uint //for ranked polls these are first preferences
+	Rounds     []Round       `json:",omitempty"`
+	Winners    []uint        //more than one winner means a tie
+}
+
+// Tally counts all of the votes cast for the provided poll.  Votes for
+// other polls are ignored.  Single choice and multi-select polls are
+// counted by adding up every selected option, ranked choice polls are
+// counted using instant-runoff
+func Tally(p *poll.Poll, votes []Vote) PollResults {
+	var ballots [][]uint
+	for _, v := range votes {
+		if v.PollID == p.PollID {
+			ballots = append(ballots, v.VoteValue)
+		}
+	}
+
+	results := PollResults{
+		PollID:     p.PollID,
+		PollType:   p.PollType,
+		TotalVotes: uint(len(ballots)),
+		Counts:     make(map[uint]uint),
+		Winners:    []uint{},
+	}
+
+	for _, id := range p.OptionIDs() {
+		results.Counts[id] = 0
+	}
+
+	if p.PollType == poll.RankedChoice {
+		for _, b := range ballots {
+			if len(b) > 0 {
+				results.Counts[b[0]]++
+			}
+		}
+		results.Rounds, results.Winners = instantRunoff(p.OptionIDs(), ballots)
+		return results
+	}
+
+	for _, b := range ballots {
+		for _, id := range b {
+			results.Counts[id]++
+		}
+	}
+	results.Winners = leaders(results.Counts)
+	return results
+}
+
+// instantRunoff repeatedly counts each ballot towards its highest ranked
+// option that is still in the race.  If an option holds a majority of the
+// ballots that are still counting it wins, otherwise the option(s) with the
+// fewest ballots are eliminated and the count is repeated.  If every
+// remaining option is tied for last place they are all returned as winners
+func instantRunoff(options []uint, ballots [][]uint) ([]Round, []uint) {
+	active := make(map[uint]bool)
+	for _, id := range options {
+		active[id] = true
+	}
+
+	var rounds []Round
+	for len(active) > 0 {
+		round := Round{
+			Round:      len(rounds) + 1,
+			Counts:     make(map[uint]uint),
+			Eliminated: []uint{},
+		}
+		for id := range active {
+			round.Counts[id] = 0
+		}
+
+		var continuing uint
+		for _, b := range ballots {
+			counted := false
+			for _, id := range b {
+				if active[id] {
+					round.Counts[id]++
+					continuing++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				round.Exhausted++
+			}
+		}
+
+		if continuing == 0 {
+			//no ballot ranks anything that is left, so nobody can win
+			rounds = append(rounds, round)
+			return rounds, []uint{}
+		}
+
+		//we have a winner if a single option is left, or if an option
+		//holds a strict majority of the ballots still being counted
+		for id, count := range round.Counts {
+			if len(active) == 1 || count*2 > continuing {
+				rounds = append(rounds, round)
+				return rounds, []uint{id}
+			}
+		}
+
+		lowest := trailers(round.Counts)
+		if len(lowest) == len(active) {
+			//everyone left is tied, there is nobody to eliminate
+			rounds = append(rounds, round)
+			return rounds, lowest
+		}
+
+		for _, id := range lowest {
+			delete(active, id)
+		}
+		round.Eliminated = lowest
+		rounds = append(rounds, round)
+	}
+
+	return rounds, []uint{}
+}
+
+// leaders returns the sorted ids with the highest non-zero count
+func leaders(counts map[uint]uint) []uint {
+	var best uint
+	for _, c := range counts {
+		if c > best {
+			best = c
+		}
+	}
+	ids := []uint{}
+	if best == 0 {
+		return ids
+	}
+	for id, c := range counts {
+		if c == best {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// trailers returns the sorted ids with the lowest count
+func trailers(counts map[uint]uint) []uint {
+	first := true
+	var worst uint
+	for _, c := range counts {
+		if first || c < worst {
+			worst = c
+			first = false
+		}
+	}
+	ids := []uint{}
+	for id, c := range counts {
+		if c == worst {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (r *PollResults) ToJson() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
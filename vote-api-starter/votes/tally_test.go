@@ -0,0 +1,167 @@
+package election_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"voter-api-starter/poll"
+	election "voter-api-starter/votes"
+)
+
+// newPoll makes a poll of the type with options 1 to n
+func newPoll(pt poll.PollType, n uint) *poll.Poll {
+	p := poll.NewPollWithType(1, "Test", "Which one?", pt, 0)
+	for id := uint(1); id <= n; id++ {
+		p.AddOption(id, "option")
+	}
+	return p
+}
+
+// ballots turns rankings into votes for poll 1
+func ballots(rankings ...[]uint) []election.Vote {
+	votes := []election.Vote{}
+	for i, r := range rankings {
+		votes = append(votes, *election.NewVote(1, uint(i+1), uint(i+1), r...))
+	}
+	return votes
+}
+
+func TestTallyInstantRunoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    uint
+		ballots    []election.Vote
+		winners    []uint
+		rounds     int
+		eliminated [][]uint //for each round
+		exhausted  []uint   //for each round
+	}{
+		{
+			name:       "majority in the first round",
+			options:    3,
+			ballots:    ballots([]uint{1}, []uint{1, 2}, []uint{2}),
+			winners:    []uint{1},
+			rounds:     1,
+			eliminated: [][]uint{{}},
+			exhausted:  []uint{0},
+		},
+		{
+			name:       "last place is eliminated and transfers",
+			options:    3,
+			ballots:    ballots([]uint{1}, []uint{1}, []uint{2}, []uint{2}, []uint{3, 1}),
+			winners:    []uint{1},
+			rounds:     2,
+			eliminated: [][]uint{{3}, {}},
+			exhausted:  []uint{0, 0},
+		},
+		{
+			name:       "tied for last are eliminated together",
+			options:    4,
+			ballots:    ballots([]uint{1}, []uint{1}, []uint{2}, []uint{2}, []uint{3, 1}, []uint{4, 1}),
+			winners:    []uint{1},
+			rounds:     2,
+			eliminated: [][]uint{{3, 4}, {}},
+			exhausted:  []uint{0, 0},
+		},
+		{
+			name:       "everyone left is tied",
+			options:    2,
+			ballots:    ballots([]uint{1}, []uint{2}),
+			winners:    []uint{1, 2},
+			rounds:     1,
+			eliminated: [][]uint{{}},
+			exhausted:  []uint{0},
+		},
+		{
+			name:       "ballots run out of choices",
+			options:    3,
+			ballots:    ballots([]uint{1}, []uint{1}, []uint{2}, []uint{2}, []uint{3}),
+			winners:    []uint{1, 2},
+			rounds:     2,
+			eliminated: [][]uint{{3}, {}},
+			exhausted:  []uint{0, 1},
+		},
+		{
+			name:       "no ballots",
+			options:    2,
+			ballots:    ballots(),
+			winners:    []uint{},
+			rounds:     1,
+			eliminated: [][]uint{{}},
+			exhausted:  []uint{0},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results := election.Tally(newPoll(poll.RankedChoice, tc.options), tc.ballots)
+
+			assert.Equal(t, tc.winners, results.Winners)
+			assert.Equal(t, uint(len(tc.ballots)), results.TotalVotes)
+			if assert.Len(t, results.Rounds, tc.rounds) {
+				for i, round := range results.Rounds {
+					assert.Equal(t, i+1, round.Round)
+					assert.Equal(t, tc.eliminated[i], round.Eliminated, "round %d", i+1)
+					assert.Equal(t, tc.exhausted[i], round.Exhausted, "round %d", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestTallyRankedCountsFirstPreferences(t *testing.T) {
+	results := election.Tally(newPoll(poll.RankedChoice, 3),
+		ballots([]uint{1, 2}, []uint{2, 1}, []uint{3, 2}, []uint{3, 1}))
+
+	assert.Equal(t, map[uint]uint{1: 1, 2: 1, 3: 2}, results.Counts)
+	assert.Equal(t, map[uint]uint{1: 1, 2: 1, 3: 2}, results.Rounds[0].Counts)
+}
+
+func TestTallyCountsSelections(t *testing.T) {
+	tests := []struct {
+		name     string
+		pollType poll.PollType
+		ballots  []election.Vote
+		counts   map[uint]uint
+		winners  []uint
+	}{
+		{
+			name:     "single choice",
+			pollType: poll.SingleChoice,
+			ballots:  ballots([]uint{1}, []uint{2}, []uint{2}),
+			counts:   map[uint]uint{1: 1, 2: 2, 3: 0},
+			winners:  []uint{2},
+		},
+		{
+			name:     "multi select counts every option picked",
+			pollType: poll.MultiSelect,
+			ballots:  ballots([]uint{1, 2}, []uint{2, 3}, []uint{3}),
+			counts:   map[uint]uint{1: 1, 2: 2, 3: 2},
+			winners:  []uint{2, 3},
+		},
+		{
+			name:     "no votes has no winner",
+			pollType: poll.SingleChoice,
+			ballots:  ballots(),
+			counts:   map[uint]uint{1: 0, 2: 0, 3: 0},
+			winners:  []uint{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results := election.Tally(newPoll(tc.pollType, 3), tc.ballots)
+
+			assert.Equal(t, tc.counts, results.Counts)
+			assert.Equal(t, tc.winners, results.Winners)
+			assert.Empty(t, results.Rounds)
+		})
+	}
+}
+
+func TestTallyIgnoresOtherPolls(t *testing.T) {
+	votes := ballots([]uint{1})
+	votes = append(votes, *election.NewVote(2, 9, 9, 2), *election.NewVote(2, 10, 10, 2))
+
+	results := election.Tally(newPoll(poll.SingleChoice, 2), votes)
+	assert.Equal(t, uint(1), results.TotalVotes)
+	assert.Equal(t, []uint{1}, results.Winners)
+}
@@ -1,35 +1,77 @@
 package election
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 )
 
+// Vote is a single ballot.  For anonymous polls VoteID and VoterID are
+// left as 0, the ballot is only identified by a random BallotID and the
+// hash of the receipt that was handed back to the voter
 type Vote struct {
-	VoteID    uint
-	VoterID   uint
-	PollID    uint
-	VoteValue uint
+	VoteID      uint
+	VoterID     uint
+	PollID      uint
+	VoteValue   []uint //option ids, for ranked polls the most preferred comes first
+	BallotID    string `json:",omitempty"`
+	ReceiptHash string `json:",omitempty"`
 }
 type VoteData struct {
 	Votes []Vote //A map of VoterIDs as keys and Voter structs as values
 }
 
 // constructor for VoterList struct
-func NewVote(pid, vid, vtrid, vval uint) *Vote {
+func NewVote(pid, vid, vtrid uint, vvals ...uint) *Vote {
 	return &Vote{
 		VoteID:    vid,
 		VoterID:   vtrid,
 		PollID:    pid,
-		VoteValue: vval,
+		VoteValue: vvals,
 	}
 }
 
+// NewAnonymousBallot creates a ballot that is not linked to a voter.  It
+// also returns the receipt token for the voter, only the hash of the token
+// is stored with the ballot so the token itself is the voter's proof
+func NewAnonymousBallot(pid uint, vvals ...uint) (*Vote, string, error) {
+	ballotID, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	receipt, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &Vote{
+		PollID:      pid,
+		VoteValue:   vvals,
+		BallotID:    ballotID,
+		ReceiptHash: HashReceipt(receipt),
+	}, receipt, nil
+}
+
+func HashReceipt(receipt string) string {
+	sum := sha256.Sum256([]byte(receipt))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 func NewSampleVote() *Vote {
 	return &Vote{
 		VoteID:    1,
 		PollID:    1,
 		VoterID:   1,
-		VoteValue: 1,
+		VoteValue: []uint{1},
 	}
 }
 
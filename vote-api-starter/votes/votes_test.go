@@ -0,0 +1,44 @@
+package election_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	election "voter-api-starter/votes"
+)
+
+func TestNewAnonymousBallot(t *testing.T) {
+	ballot, receipt, err := election.NewAnonymousBallot(7, 3, 1)
+	require.NoError(t, err)
+
+	//nothing on the ballot points back at the voter
+	assert.Zero(t, ballot.VoterID)
+	assert.Zero(t, ballot.VoteID)
+	assert.Equal(t, uint(7), ballot.PollID)
+	assert.Equal(t, []uint{3, 1}, ballot.VoteValue)
+	assert.NotEmpty(t, ballot.BallotID)
+
+	//only the hash of the receipt is kept
+	assert.NotEmpty(t, receipt)
+	assert.NotEqual(t, receipt, ballot.ReceiptHash)
+	assert.Equal(t, election.HashReceipt(receipt), ballot.ReceiptHash)
+	assert.NotContains(t, ballot.ToJson(), receipt)
+}
+
+func TestAnonymousBallotsAreUnique(t *testing.T) {
+	first, firstReceipt, err := election.NewAnonymousBallot(7, 1)
+	require.NoError(t, err)
+	second, secondReceipt, err := election.NewAnonymousBallot(7, 1)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.BallotID, second.BallotID)
+	assert.NotEqual(t, firstReceipt, secondReceipt)
+	assert.NotEqual(t, first.ReceiptHash, second.ReceiptHash)
+}
+
+func TestHashReceipt(t *testing.T) {
+	assert.Equal(t, election.HashReceipt("abc"), election.HashReceipt("abc"))
+	assert.NotEqual(t, election.HashReceipt("abc"), election.HashReceipt("abd"))
+	assert.Len(t, election.HashReceipt("abc"), 64)
+}